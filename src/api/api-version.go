@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/samber/lo"
+
+	"sersh.com/totaltube/frontend/internal"
+)
+
+// SupportedApiVersions are minion api versions the frontend can work with, the preferred one first.
+var SupportedApiVersions = []string{"v1"}
+
+// NegotiateApiVersion selects the api version supported by both frontend and minion.
+// Versions are taken from options or, if minion doesn't report them there, from the health endpoint.
+// If minion reports nothing, the version from api_url (or v1) is used.
+func NegotiateApiVersion(options *internal.Options) {
+	var versions []string
+	if options != nil {
+		versions = options.ApiVersions
+	}
+	if len(versions) == 0 {
+		if response, err := Request(nil, methodGet, uriHealth, nil); err == nil {
+			var health struct {
+				ApiVersions []string `json:"api_versions"`
+			}
+			if json.Unmarshal(response, &health) == nil {
+				versions = health.ApiVersions
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return
+	}
+	for _, v := range SupportedApiVersions {
+		if lo.Contains(versions, v) {
+			internal.SetNegotiatedApiVersion(v)
+			if v != internal.ApiVersion(nil) {
+				log.Println("api version", v, "negotiated, but api_version", internal.ApiVersion(nil), "is forced in config")
+			}
+			return
+		}
+	}
+	log.Println("minion doesn't support any of api versions", SupportedApiVersions, "it supports only", versions)
+}
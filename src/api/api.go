@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -87,6 +88,13 @@ func Request(siteConfig *types.Config, method Method, uri ApiUri, data interface
 	if method != methodGet && ApiWriteHasTrouble.Load() {
 		//return nil, ErrApiWriteTrouble
 	}
+	if method != methodGet && uri != uriHealth && internal.IsOffline() {
+		return nil, ErrApiWriteTrouble
+	}
+	if trace := siteConfig.Trace(); trace != nil {
+		started := time.Now()
+		defer func() { trace.AddCall("api", string(method)+" "+string(uri), started, err) }()
+	}
 	siteName := internal.Config.General.ApiUrl
 	if siteConfig != nil {
 		siteName = siteConfig.Hostname
@@ -107,41 +115,10 @@ func Request(siteConfig *types.Config, method Method, uri ApiUri, data interface
 	resp, err = f.Do()
 	if err != nil {
 		err = errors.Wrap(err, "error getting "+internal.Config.General.ApiUrl+string(uri))
-		if method == methodGet {
-			errCount := apiReadErrCount.Add(1)
-			if errCount > errsForTrouble {
-				func() {
-					apiCheckMutex.Lock()
-					defer apiCheckMutex.Unlock()
-					if apiCheckRunning {
-						return
-					}
-					ApiHasTrouble.Store(true)
-					apiCheckRunning = true
-					go periodicCheckApi()
-				}()
-			}
-		} else {
-			errCount := apiWriteErrCount.Add(1)
-			if errCount > errsForTrouble {
-				func() {
-					apiCheckMutex.Lock()
-					defer apiCheckMutex.Unlock()
-					if apiCheckRunning {
-						return
-					}
-					ApiWriteHasTrouble.Store(true)
-					apiCheckRunning = true
-					go periodicCheckApi()
-				}()
-			}
-		}
+		trackApiResult(method, err)
 		return
-	} else if method == methodGet {
-		apiReadErrCount.Store(0)
-	} else {
-		apiWriteErrCount.Store(0)
 	}
+	trackApiResult(method, nil)
 	var r apiResponse
 	err = json.Unmarshal(resp, &r)
 	if err != nil {
@@ -158,9 +135,55 @@ func Request(siteConfig *types.Config, method Method, uri ApiUri, data interface
 		return
 	}
 	response = r.Value
+	if method == methodGet && internal.StrictApiDecoding() {
+		checkResponseSchema(uri, response)
+	}
 	return
 }
 
+// trackApiResult counts failed api requests. After errsForTrouble failures in a row api is marked as having trouble,
+// the frontend goes offline and api is checked periodically until it's back.
+func trackApiResult(method Method, err error) {
+	if err == nil {
+		if method == methodGet {
+			apiReadErrCount.Store(0)
+		} else {
+			apiWriteErrCount.Store(0)
+		}
+		return
+	}
+	if method == methodGet {
+		errCount := apiReadErrCount.Add(1)
+		if errCount > errsForTrouble {
+			func() {
+				apiCheckMutex.Lock()
+				defer apiCheckMutex.Unlock()
+				if apiCheckRunning {
+					return
+				}
+				ApiHasTrouble.Store(true)
+				internal.SetOffline(true)
+				apiCheckRunning = true
+				go periodicCheckApi()
+			}()
+		}
+	} else {
+		errCount := apiWriteErrCount.Add(1)
+		if errCount > errsForTrouble {
+			func() {
+				apiCheckMutex.Lock()
+				defer apiCheckMutex.Unlock()
+				if apiCheckRunning {
+					return
+				}
+				ApiWriteHasTrouble.Store(true)
+				apiCheckRunning = true
+				go periodicCheckApi()
+			}()
+		}
+	}
+}
+
 func periodicCheckApi() {
 	defer func() {
 		apiCheckMutex.Lock()
@@ -171,6 +194,7 @@ func periodicCheckApi() {
 		if ApiHasTrouble.Load() {
 			if _, err := Request(nil, methodGet, uriHealth, nil); err == nil {
 				ApiHasTrouble.Store(false)
+				internal.SetOffline(false)
 				apiReadErrCount.Store(0)
 				apiWriteErrCount.Store(0)
 			}
@@ -179,6 +203,22 @@ func periodicCheckApi() {
 				ApiWriteHasTrouble.Store(false)
 				apiWriteErrCount.Store(0)
 			}
+		} else {
+			return
 		}
+		time.Sleep(time.Second)
+	}
+}
+
+// MarkUnavailable is used when minion is not reachable on startup: the sites are served in offline mode
+// until the api becomes healthy again.
+func MarkUnavailable() {
+	apiCheckMutex.Lock()
+	defer apiCheckMutex.Unlock()
+	ApiHasTrouble.Store(true)
+	internal.SetOffline(true)
+	if !apiCheckRunning {
+		apiCheckRunning = true
+		go periodicCheckApi()
 	}
 }
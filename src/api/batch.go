@@ -0,0 +1,398 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+const uriBatch ApiUri = "batch"
+
+// How long we don't try the batch endpoint of the api again after it failed. Minions without batch support
+// are served with parallel requests in the meantime.
+const batchRetryInterval = time.Minute * 10
+
+// Maximum amount of calls remembered for one template.
+const maxPlannedCalls = 50
+
+var batchUnavailable sync.Map // api url -> time.Time until batch endpoint is not used
+
+// batchPlans keeps calls made by templates during render, so the next render can send them beforehand.
+var batchPlans sync.Map // plan key -> *batchPlan
+
+type plannedCall struct {
+	key      string
+	uri      ApiUri
+	params   url.Values
+	cacheKey string // db cache key of the call result, if the template caches it
+}
+
+type batchPlan struct {
+	seen   map[string]bool // calls made during the last render
+	stable []plannedCall   // calls made during the last two renders, these are prefetched
+}
+
+type batchSubRequest struct {
+	Method string `json:"method"`
+	Uri    string `json:"uri"`
+	Query  string `json:"query"`
+}
+
+// BatchCall is one sub-request of the Batch. Result is available after the batch is executed,
+// which happens on the first Raw or Result call of any of batch calls.
+type BatchCall struct {
+	batch      *Batch
+	key        string
+	uri        ApiUri
+	params     url.Values
+	decode     func(raw json.RawMessage) (interface{}, error)
+	cached     bool
+	remembered bool
+	done       bool
+	decoded    bool
+	value      interface{}
+	raw        json.RawMessage
+	err        error
+}
+
+// Batch collects independent api requests of a page render and sends them to minion in one round trip.
+// Calls with the same uri and params are made only once.
+type Batch struct {
+	sync.Mutex
+	siteConfig *types.Config
+	calls      map[string]*BatchCall
+	pending    []*BatchCall
+	planKey    string
+	plan       *batchPlan
+	used       []plannedCall
+}
+
+func NewBatch(siteConfig *types.Config) *Batch {
+	return &Batch{siteConfig: siteConfig, calls: map[string]*BatchCall{}}
+}
+
+// Add adds GET request to batch. If result is not nil, response will be unmarshalled into it.
+func (b *Batch) Add(uri ApiUri, params url.Values, result interface{}) *BatchCall {
+	var decode func(raw json.RawMessage) (interface{}, error)
+	if result != nil {
+		decode = func(raw json.RawMessage) (interface{}, error) {
+			return result, json.Unmarshal(raw, result)
+		}
+	}
+	return b.add(uri, params, decode)
+}
+
+func (b *Batch) add(uri ApiUri, params url.Values, decode func(raw json.RawMessage) (interface{}, error)) *BatchCall {
+	b.Lock()
+	defer b.Unlock()
+	key := string(uri) + "?" + params.Encode()
+	if call, ok := b.calls[key]; ok {
+		if call.decode == nil {
+			call.decode = decode
+		}
+		return call
+	}
+	call := &BatchCall{batch: b, key: key, uri: uri, params: params, decode: decode}
+	b.calls[key] = call
+	b.pending = append(b.pending, call)
+	return call
+}
+
+// Cached marks the call as already cached, so it's not sent with the batch. If the result of such call
+// is requested anyway (cache expired meanwhile), the call is sent alone.
+func (c *BatchCall) Cached(cached bool) *BatchCall {
+	c.batch.Lock()
+	defer c.batch.Unlock()
+	c.cached = cached
+	return c
+}
+
+// Raw returns raw api response for the call, executing the batch if it was not executed yet.
+func (c *BatchCall) Raw() ([]byte, error) {
+	c.batch.Lock()
+	defer c.batch.Unlock()
+	if !c.done {
+		c.batch.send(c)
+	}
+	return c.raw, c.err
+}
+
+// Result returns the typed result of the call, executing the batch if it was not executed yet.
+func (c *BatchCall) Result() (interface{}, error) {
+	c.batch.Lock()
+	defer c.batch.Unlock()
+	if !c.done {
+		c.batch.send(c)
+	}
+	if c.err != nil || c.decoded || c.decode == nil {
+		return c.value, c.err
+	}
+	c.decoded = true
+	if c.value, c.err = c.decode(c.raw); c.err != nil {
+		log.Println(c.err, c.batch.siteName(), c.uri, string(c.raw))
+	}
+	return c.value, c.err
+}
+
+// Prefetch adds to the batch calls which the template made during its previous renders, so they
+// are sent in one round trip. Calls with results in db cache, for which cached returns true, are skipped.
+// It's called on output cache miss only, calls the template makes are remembered with Remember.
+func (b *Batch) Prefetch(planKey string, cached func(cacheKey string) bool) {
+	b.Lock()
+	b.planKey = planKey
+	b.plan = nil
+	if plan, ok := batchPlans.Load(planKey); ok {
+		b.plan = plan.(*batchPlan)
+	}
+	plan := b.plan
+	b.Unlock()
+	if plan == nil {
+		return
+	}
+	for _, p := range plan.stable {
+		if p.cacheKey != "" && cached != nil && cached(p.cacheKey) {
+			continue
+		}
+		b.add(p.uri, p.params, nil)
+	}
+}
+
+// Remember marks the call as made by the page template. Calls made during two renders in a row are
+// prefetched by the next render of the template.
+func (c *BatchCall) Remember() *BatchCall {
+	return c.RememberCached("")
+}
+
+// RememberCached is Remember for calls the template keeps in db cache with cacheKey. Such calls are not
+// prefetched while the cache is fresh.
+func (c *BatchCall) RememberCached(cacheKey string) *BatchCall {
+	b := c.batch
+	b.Lock()
+	defer b.Unlock()
+	if b.planKey == "" || c.remembered || len(b.used) >= maxPlannedCalls {
+		return c
+	}
+	c.remembered = true
+	b.used = append(b.used, plannedCall{key: c.key, uri: c.uri, params: c.params, cacheKey: cacheKey})
+	plan := &batchPlan{seen: make(map[string]bool, len(b.used))}
+	for _, p := range b.used {
+		plan.seen[p.key] = true
+		if b.plan != nil && b.plan.seen[p.key] {
+			plan.stable = append(plan.stable, p)
+		}
+	}
+	batchPlans.Store(b.planKey, plan)
+	return c
+}
+
+// Do sends all pending calls, except cached ones. Normally it's not needed to call it directly.
+func (b *Batch) Do() {
+	b.Lock()
+	defer b.Unlock()
+	b.send(nil)
+}
+
+// send executes pending not cached calls together with the call requested. Must be called with batch locked.
+func (b *Batch) send(requested *BatchCall) {
+	var toSend, rest []*BatchCall
+	for _, call := range b.pending {
+		if !call.cached || call == requested {
+			toSend = append(toSend, call)
+		} else {
+			rest = append(rest, call)
+		}
+	}
+	b.pending = rest
+	if len(toSend) == 0 {
+		return
+	}
+	apiUrl := b.apiUrl()
+	if len(toSend) == 1 {
+		toSend[0].raw, toSend[0].err = Request(b.siteConfig, methodGet, toSend[0].uri, toSend[0].params)
+	} else if until, ok := batchUnavailable.Load(apiUrl); ok && time.Now().Before(until.(time.Time)) {
+		b.doParallel(toSend)
+	} else if err := b.doBatch(toSend); err != nil {
+		log.Println("batch request failed, falling back to parallel requests:", err, b.siteName())
+		batchUnavailable.Store(apiUrl, time.Now().Add(batchRetryInterval))
+		b.doParallel(toSend)
+	}
+	for _, call := range toSend {
+		call.done = true
+	}
+}
+
+func (b *Batch) siteName() string {
+	if b.siteConfig != nil {
+		return b.siteConfig.Hostname
+	}
+	return internal.Config.General.ApiUrl
+}
+
+// apiUrl returns the api url the batch is sent to. Sites may use their own api.
+func (b *Batch) apiUrl() string {
+	apiUrl := internal.Config.General.ApiUrl
+	if b.siteConfig != nil && b.siteConfig.General.ApiUrl != "" {
+		apiUrl = b.siteConfig.General.ApiUrl
+	}
+	return apiUrl + internal.ApiVersion(b.siteConfig)
+}
+
+func (b *Batch) doParallel(pending []*BatchCall) {
+	var wg sync.WaitGroup
+	for _, call := range pending {
+		wg.Add(1)
+		go func(call *BatchCall) {
+			defer wg.Done()
+			call.raw, call.err = Request(b.siteConfig, methodGet, call.uri, call.params)
+		}(call)
+	}
+	wg.Wait()
+}
+
+func (b *Batch) doBatch(pending []*BatchCall) (err error) {
+	if trace := b.siteConfig.Trace(); trace != nil {
+		started := time.Now()
+		defer func() { trace.AddCall("api", "batch "+strconv.Itoa(len(pending))+" calls", started, err) }()
+	}
+	requests := make([]batchSubRequest, 0, len(pending))
+	for _, call := range pending {
+		requests = append(requests, batchSubRequest{
+			Method: string(methodGet),
+			Uri:    string(call.uri),
+			Query:  call.params.Encode(),
+		})
+	}
+	var resp []byte
+	resp, err = helpers.SiteFetch(b.siteConfig)(string(uriBatch)).
+		WithJsonData(map[string]interface{}{"requests": requests}).
+		Do()
+	// batch consists of read requests only, so it's counted as one read request
+	trackApiResult(methodGet, err)
+	if err != nil {
+		return
+	}
+	var r apiResponse
+	if err = json.Unmarshal(resp, &r); err != nil {
+		return
+	}
+	if !r.Success {
+		var errorString string
+		_ = json.Unmarshal(r.Value, &errorString)
+		return errors.New("error from api: " + errorString)
+	}
+	var responses []apiResponse
+	if err = json.Unmarshal(r.Value, &responses); err != nil {
+		return
+	}
+	if len(responses) != len(pending) {
+		return errors.New("wrong amount of batch responses: " + strconv.Itoa(len(responses)) + " instead of " + strconv.Itoa(len(pending)))
+	}
+	for k, call := range pending {
+		if !responses[k].Success {
+			var errorString string
+			_ = json.Unmarshal(responses[k].Value, &errorString)
+			call.err = errors.New("error from api: " + errorString + ", " + string(methodGet) + ", " + string(call.uri))
+			if !strings.Contains(errorString, "not found") {
+				log.Printf("error from api: %s, %s, %s, %s", errorString, b.siteName(), methodGet, call.uri)
+			}
+			continue
+		}
+		call.raw = responses[k].Value
+		if internal.StrictApiDecoding() {
+			checkResponseSchema(call.uri, call.raw)
+		}
+	}
+	return nil
+}
+
+func decodeInto[T any](after func(result *T)) func(raw json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		result := new(T)
+		if err := json.Unmarshal(raw, result); err != nil {
+			return nil, err
+		}
+		if after != nil {
+			after(result)
+		}
+		return result, nil
+	}
+}
+
+func decodeSearches(raw json.RawMessage) (interface{}, error) {
+	result := struct {
+		Items []types.TopSearch `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+func (b *Batch) CategoryInfo(lang string, categoryId int64, categorySlug string) *BatchCall {
+	return b.add(uriCategoryInfo, categoryInfoValues(lang, categoryId, categorySlug), decodeInto[types.CategoryResult](nil))
+}
+
+func (b *Batch) ChannelInfo(lang string, channelId int64, channelSlug string) *BatchCall {
+	return b.add(uriChannelInfo, channelInfoValues(lang, channelId, channelSlug), decodeInto[types.ChannelResult](nil))
+}
+
+func (b *Batch) ModelInfo(lang string, id int64, slug string, groupId int64) *BatchCall {
+	return b.add(uriModel, modelInfoValues(lang, id, slug, groupId), decodeInto[types.ModelResult](nil))
+}
+
+func (b *Batch) Category(lang string, categoryId int64, categorySlug string, page int64, groupId int64, additionalLanguages []string) *BatchCall {
+	return b.add(uriCategory, categoryValues(lang, categoryId, categorySlug, page, groupId, additionalLanguages), decodeInto[types.ContentResults](nil))
+}
+
+func (b *Batch) Content(params ContentParams) *BatchCall {
+	return b.add(uriContent, contentValues(params), decodeInto(func(results *types.ContentResults) {
+		for k := range results.Items {
+			results.Items[k].ThumbsHeight = results.Items[k].ThumbHeight
+			results.Items[k].ThumbsWidth = results.Items[k].ThumbWidth
+		}
+	}))
+}
+
+func (b *Batch) ContentItem(lang, slug string, id int64, omitRelatedForLink bool, relatedAmount int64, groupId int64,
+	related *RelatedParams) *BatchCall {
+	return b.add(uriContentItem, contentItemValues(lang, slug, id, omitRelatedForLink, relatedAmount, groupId, related),
+		decodeInto[types.ContentItemResult](nil))
+}
+
+func (b *Batch) TopContent(lang string, page int64, groupId int64, additionalLanguages []string) *BatchCall {
+	return b.add(uriTopContent, topContentValues(lang, page, groupId, additionalLanguages), decodeInto[types.ContentResults](nil))
+}
+
+func (b *Batch) TopCategories(lang string, page int64, groupId int64) *BatchCall {
+	return b.add(uriTopCategories, topCategoriesValues(lang, page, groupId), decodeInto[types.CategoryResults](nil))
+}
+
+func (b *Batch) CategoriesList(lang string, page int64, sort SortBy, amount int64, groupId int64) *BatchCall {
+	return b.add(uriCategoriesList, categoriesListValues(lang, page, sort, amount, groupId), decodeInto[types.CategoryResults](nil))
+}
+
+func (b *Batch) ChannelsList(lang string, page int64, sort SortBy, amount int64, groupId int64) *BatchCall {
+	return b.add(uriChannelsList, channelsListValues(lang, page, sort, amount, groupId), decodeInto[types.ChannelResults](nil))
+}
+
+func (b *Batch) ModelsList(lang string, page int64, sort SortBy, amount int64, searchQuery string, groupId int64) *BatchCall {
+	return b.add(uriModelsList, modelsListValues(lang, page, sort, amount, searchQuery, groupId), decodeInto[types.ModelResults](nil))
+}
+
+func (b *Batch) TopSearches(lang string, amount int64) *BatchCall {
+	return b.add(uriTopSearches, topSearchesValues(lang, amount), decodeSearches)
+}
+
+func (b *Batch) RandomSearches(lang string, amount int64, minSearches int64) *BatchCall {
+	return b.add(uriRandomSearches, randomSearchesValues(lang, amount, minSearches), decodeSearches)
+}
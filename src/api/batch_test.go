@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// testMinion answers every GET request with its uri and query and the batch endpoint with the list of sub-responses.
+type testMinion struct {
+	sync.Mutex
+	noBatch  bool
+	requests []string
+}
+
+func (m *testMinion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := strings.TrimPrefix(r.URL.Path, "/v1/")
+	m.Lock()
+	m.requests = append(m.requests, uri)
+	m.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if uri == string(uriBatch) {
+		if m.noBatch {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"success":false,"value":"not found"}`))
+			return
+		}
+		var body struct {
+			Requests []batchSubRequest `json:"requests"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		responses := make([]apiResponse, 0, len(body.Requests))
+		for _, sub := range body.Requests {
+			responses = append(responses, testMinionResponse(sub.Uri, sub.Query))
+		}
+		value, _ := json.Marshal(responses)
+		_ = json.NewEncoder(w).Encode(apiResponse{Success: true, Value: value})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(testMinionResponse(uri, r.URL.RawQuery))
+}
+
+func (m *testMinion) calls() []string {
+	m.Lock()
+	defer m.Unlock()
+	return append([]string(nil), m.requests...)
+}
+
+func testMinionResponse(uri, query string) apiResponse {
+	value, _ := json.Marshal(map[string]string{"title": uri + "?" + query})
+	return apiResponse{Success: true, Value: value}
+}
+
+func startTestMinion(t *testing.T, noBatch bool) *testMinion {
+	minion := &testMinion{noBatch: noBatch}
+	server := httptest.NewServer(minion)
+	t.Cleanup(server.Close)
+	config := useTestConfig(t)
+	config.General.ApiUrl = server.URL + "/"
+	config.General.ApiTimeout = types.Duration(time.Second * 5)
+	return minion
+}
+
+// useTestConfig replaces global config with empty one for the test.
+func useTestConfig(t *testing.T) *internal.ConfigT {
+	config := internal.Config
+	internal.Config = &internal.ConfigT{}
+	t.Cleanup(func() { internal.Config = config })
+	return internal.Config
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		noBatch bool
+		cached  []bool // one call per element, with the cached flag
+		want    []string
+	}{
+		{name: "single call is sent alone", cached: []bool{false}, want: []string{"category-info"}},
+		{name: "calls are sent in one batch", cached: []bool{false, false, false}, want: []string{"batch"}},
+		{name: "cached calls are not sent", cached: []bool{true, false, true}, want: []string{"category-info"}},
+		{name: "all calls cached", cached: []bool{true, true}, want: nil},
+		{name: "no batch support", noBatch: true, cached: []bool{false, false},
+			want: []string{"batch", "category-info", "category-info"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minion := startTestMinion(t, tt.noBatch)
+			batch := NewBatch(nil)
+			var calls []*BatchCall
+			for k, cached := range tt.cached {
+				calls = append(calls, batch.CategoryInfo("en", int64(k+1), "").Cached(cached))
+			}
+			batch.Do()
+			if got := minion.calls(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("requests = %v, want %v", got, tt.want)
+			}
+			for k, call := range calls {
+				if tt.cached[k] {
+					continue
+				}
+				result, err := call.Result()
+				if err != nil {
+					t.Fatalf("call %d: %v", k, err)
+				}
+				want := "category-info?" + categoryInfoValues("en", int64(k+1), "").Encode()
+				if title := result.(*types.CategoryResult).Title; title != want {
+					t.Fatalf("call %d: title = %q, want %q", k, title, want)
+				}
+			}
+			if got := len(minion.calls()); got != len(tt.want) {
+				t.Fatalf("results of executed calls caused %d more requests", got-len(tt.want))
+			}
+			batchUnavailable.Delete(batch.apiUrl())
+		})
+	}
+}
+
+func TestBatchCachedCallRequestedAnyway(t *testing.T) {
+	minion := startTestMinion(t, false)
+	batch := NewBatch(nil)
+	cached := batch.CategoryInfo("en", 1, "").Cached(true)
+	batch.TopSearches("en", 10)
+	batch.ModelInfo("en", 1, "", 0)
+	batch.Do()
+	if _, err := cached.Raw(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(minion.calls(), ","); got != "batch,category-info" {
+		t.Fatalf("requests = %s", got)
+	}
+}
+
+func TestBatchDeduplicates(t *testing.T) {
+	minion := startTestMinion(t, false)
+	batch := NewBatch(nil)
+	first := batch.TopSearches("en", 10)
+	second := batch.TopSearches("en", 10)
+	if first != second {
+		t.Fatal("same call is added twice")
+	}
+	if _, err := second.Result(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(minion.calls()); got != 1 {
+		t.Fatalf("%d requests instead of 1", got)
+	}
+}
+
+func TestBatchUnavailablePerApiUrl(t *testing.T) {
+	minion := startTestMinion(t, true)
+	batch := NewBatch(nil)
+	batch.CategoryInfo("en", 1, "")
+	batch.CategoryInfo("en", 2, "")
+	batch.Do()
+	defer batchUnavailable.Delete(batch.apiUrl())
+	if _, ok := batchUnavailable.Load(batch.apiUrl()); !ok {
+		t.Fatal("batch endpoint is not marked unavailable")
+	}
+	other := NewBatch(&types.Config{General: types.ConfigGeneral{ApiUrl: "http://other.example/"}})
+	if _, ok := batchUnavailable.Load(other.apiUrl()); ok {
+		t.Fatal("batch endpoint of other api is marked unavailable")
+	}
+	// next batch to the same api doesn't try the batch endpoint
+	batch = NewBatch(nil)
+	batch.CategoryInfo("en", 3, "")
+	batch.CategoryInfo("en", 4, "")
+	batch.Do()
+	if got := strings.Count(strings.Join(minion.calls(), ","), "batch"); got != 1 {
+		t.Fatalf("batch endpoint requested %d times", got)
+	}
+}
+
+func TestBatchCountsErrors(t *testing.T) {
+	startTestMinion(t, false)
+	apiReadErrCount.Store(5)
+	defer apiReadErrCount.Store(0)
+	batch := NewBatch(nil)
+	batch.CategoryInfo("en", 1, "")
+	batch.CategoryInfo("en", 2, "")
+	batch.Do()
+	if got := apiReadErrCount.Load(); got != 0 {
+		t.Fatalf("read errors counter = %d after successful batch", got)
+	}
+	internal.Config.General.ApiUrl = "http://127.0.0.1:1/"
+	batch = NewBatch(nil)
+	batch.CategoryInfo("en", 1, "")
+	batch.CategoryInfo("en", 2, "")
+	batch.Do()
+	batchUnavailable.Delete(batch.apiUrl())
+	// batch request and both fallback requests failed
+	if got := apiReadErrCount.Load(); got != 3 {
+		t.Fatalf("read errors counter = %d, want 3", got)
+	}
+}
+
+func TestBatchPrefetch(t *testing.T) {
+	minion := startTestMinion(t, false)
+	const planKey = "example.com:en:index"
+	defer batchPlans.Delete(planKey)
+	render := func(topSearchesAmount int64) {
+		batch := NewBatch(nil)
+		batch.Prefetch(planKey, nil)
+		prepare := batch.CategoryInfo("en", 1, "")
+		if _, err := prepare.Raw(); err != nil {
+			t.Fatal(err)
+		}
+		// template functions
+		if _, err := batch.TopSearches("en", 10).Remember().Result(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := batch.TopSearches("en", topSearchesAmount).Remember().Result(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{amount: 1, want: "category-info,searches/top,searches/top"},
+		// top searches with amount 10 are made during two renders in a row, they are prefetched next time
+		{amount: 2, want: "category-info,searches/top,searches/top"},
+		{amount: 3, want: "batch,searches/top"},
+		{amount: 3, want: "batch,searches/top"},
+		{amount: 3, want: "batch"},
+	}
+	for k, tt := range tests {
+		before := len(minion.calls())
+		render(tt.amount)
+		if got := strings.Join(minion.calls()[before:], ","); got != tt.want {
+			t.Fatalf("render %d: requests = %s, want %s", k+1, got, tt.want)
+		}
+	}
+}
+
+func TestBatchPrefetchCached(t *testing.T) {
+	minion := startTestMinion(t, false)
+	const planKey = "example.com:en:category"
+	defer batchPlans.Delete(planKey)
+	render := func(cached bool) {
+		batch := NewBatch(nil)
+		batch.Prefetch(planKey, func(cacheKey string) bool { return cached && cacheKey == "in:searches" })
+		if _, err := batch.CategoryInfo("en", 1, "").Remember().Raw(); err != nil {
+			t.Fatal(err)
+		}
+		if cached {
+			return
+		}
+		if _, err := batch.TopSearches("en", 10).RememberCached("in:searches").Raw(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		cached bool
+		want   string
+	}{
+		{want: "category-info,searches/top"},
+		{want: "category-info,searches/top"},
+		{want: "batch"},
+		// result of top searches is in the cache, so it's not requested
+		{cached: true, want: "category-info"},
+	}
+	for k, tt := range tests {
+		before := len(minion.calls())
+		render(tt.cached)
+		if got := strings.Join(minion.calls()[before:], ","); got != tt.want {
+			t.Fatalf("render %d: requests = %s, want %s", k+1, got, tt.want)
+		}
+	}
+}
+
+func TestBatchCallParams(t *testing.T) {
+	batch := NewBatch(nil)
+	tests := []struct {
+		call *BatchCall
+		uri  ApiUri
+		want url.Values
+	}{
+		{batch.TopContent("en", 2, 1, nil), uriTopContent, topContentValues("en", 2, 1, nil)},
+		{batch.TopCategories("en", 2, 1), uriTopCategories, topCategoriesValues("en", 2, 1)},
+		{batch.CategoriesList("en", 1, SortTitle, 10, 1), uriCategoriesList, categoriesListValues("en", 1, SortTitle, 10, 1)},
+		{batch.ChannelsList("en", 1, SortTitle, 10, 1), uriChannelsList, channelsListValues("en", 1, SortTitle, 10, 1)},
+		{batch.ModelsList("en", 1, SortTitle, 10, "q", 1), uriModelsList, modelsListValues("en", 1, SortTitle, 10, "q", 1)},
+		{batch.RandomSearches("en", 10, 2), uriRandomSearches, randomSearchesValues("en", 10, 2)},
+	}
+	for _, tt := range tests {
+		if tt.call.uri != tt.uri || tt.call.params.Encode() != tt.want.Encode() {
+			t.Errorf("call %s?%s, want %s?%s", tt.call.uri, tt.call.params.Encode(), tt.uri, tt.want.Encode())
+		}
+	}
+}
@@ -10,13 +10,7 @@ import (
 
 func CategoriesList(siteConfig *types.Config, lang string, page int64, sort SortBy, amount int64, groupId int64) (
 	results *types.CategoryResults, rawResponse json.RawMessage, err error) {
-	rawResponse, err = Request(siteConfig, methodGet, uriCategoriesList, url.Values{
-		"lang":     []string{lang},
-		"sort":     []string{string(sort)},
-		"amount":   []string{strconv.FormatInt(amount, 10)},
-		"page":     []string{strconv.FormatInt(page, 10)},
-		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	})
+	rawResponse, err = Request(siteConfig, methodGet, uriCategoriesList, categoriesListValues(lang, page, sort, amount, groupId))
 	if err != nil {
 		return
 	}
@@ -24,3 +18,13 @@ func CategoriesList(siteConfig *types.Config, lang string, page int64, sort Sort
 	err = json.Unmarshal(rawResponse, &results)
 	return
 }
+
+func categoriesListValues(lang string, page int64, sort SortBy, amount int64, groupId int64) url.Values {
+	return url.Values{
+		"lang":     []string{lang},
+		"sort":     []string{string(sort)},
+		"amount":   []string{strconv.FormatInt(amount, 10)},
+		"page":     []string{strconv.FormatInt(page, 10)},
+		"group_id": []string{strconv.FormatInt(groupId, 10)},
+	}
+}
@@ -9,11 +9,7 @@ import (
 )
 
 func CategoryInfo(siteConfig *types.Config, lang string, categoryId int64, categorySlug string) (result *types.CategoryResult, rawResponse json.RawMessage, err error) {
-	rawResponse, err = Request(siteConfig, methodGet, uriCategoryInfo, url.Values{
-		"id":   []string{strconv.FormatInt(categoryId, 10)},
-		"slug": []string{categorySlug},
-		"lang": []string{lang},
-	})
+	rawResponse, err = Request(siteConfig, methodGet, uriCategoryInfo, categoryInfoValues(lang, categoryId, categorySlug))
 	if err != nil {
 		return
 	}
@@ -21,3 +17,11 @@ func CategoryInfo(siteConfig *types.Config, lang string, categoryId int64, categ
 	err = json.Unmarshal(rawResponse, result)
 	return
 }
+
+func categoryInfoValues(lang string, categoryId int64, categorySlug string) url.Values {
+	return url.Values{
+		"id":   []string{strconv.FormatInt(categoryId, 10)},
+		"slug": []string{categorySlug},
+		"lang": []string{lang},
+	}
+}
@@ -11,17 +11,7 @@ import (
 
 func Category(siteConfig *types.Config, lang string, categoryId int64, categorySlug string, page int64, groupId int64, additionalLanguages []string) (results *types.ContentResults, err error) {
 	var response json.RawMessage
-	data := url.Values{
-		"id":       []string{strconv.FormatInt(categoryId, 10)},
-		"slug":     []string{categorySlug},
-		"lang":     []string{lang},
-		"page":     []string{strconv.FormatInt(page, 10)},
-		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	}
-	if len(additionalLanguages) > 0 {
-		data.Add("additional_languages", strings.Join(additionalLanguages, ","))
-	}
-	response, err = Request(siteConfig, methodGet, uriCategory, data)
+	response, err = Request(siteConfig, methodGet, uriCategory, categoryValues(lang, categoryId, categorySlug, page, groupId, additionalLanguages))
 	if err != nil {
 		return
 	}
@@ -31,6 +21,11 @@ func Category(siteConfig *types.Config, lang string, categoryId int64, categoryS
 }
 
 func CategoryRaw(siteConfig *types.Config, lang string, categoryId int64, categorySlug string, page int64, groupId int64, additionalLanguages []string) (response json.RawMessage, err error) {
+	response, err = Request(siteConfig, methodGet, uriCategory, categoryValues(lang, categoryId, categorySlug, page, groupId, additionalLanguages))
+	return
+}
+
+func categoryValues(lang string, categoryId int64, categorySlug string, page int64, groupId int64, additionalLanguages []string) url.Values {
 	data := url.Values{
 		"id":       []string{strconv.FormatInt(categoryId, 10)},
 		"slug":     []string{categorySlug},
@@ -41,6 +36,5 @@ func CategoryRaw(siteConfig *types.Config, lang string, categoryId int64, catego
 	if len(additionalLanguages) > 0 {
 		data.Add("additional_languages", strings.Join(additionalLanguages, ","))
 	}
-	response, err = Request(siteConfig, methodGet, uriCategory, data)
-	return
+	return data
 }
@@ -9,11 +9,7 @@ import (
 )
 
 func ChannelInfo(siteConfig *types.Config, lang string, channelInfo int64, channelSlug string) (result *types.ChannelResult, rawResponse json.RawMessage, err error) {
-	rawResponse, err = Request(siteConfig, methodGet, uriChannelInfo, url.Values{
-		"id":   []string{strconv.FormatInt(channelInfo, 10)},
-		"slug": []string{channelSlug},
-		"lang": []string{lang},
-	})
+	rawResponse, err = Request(siteConfig, methodGet, uriChannelInfo, channelInfoValues(lang, channelInfo, channelSlug))
 	if err != nil {
 		return
 	}
@@ -21,3 +17,11 @@ func ChannelInfo(siteConfig *types.Config, lang string, channelInfo int64, chann
 	err = json.Unmarshal(rawResponse, result)
 	return
 }
+
+func channelInfoValues(lang string, channelId int64, channelSlug string) url.Values {
+	return url.Values{
+		"id":   []string{strconv.FormatInt(channelId, 10)},
+		"slug": []string{channelSlug},
+		"lang": []string{lang},
+	}
+}
@@ -11,13 +11,7 @@ import (
 func ChannelsList(
 	siteConfig *types.Config, lang string, page int64, sort SortBy, amount int64, groupId int64,
 ) (results *types.ChannelResults, response json.RawMessage, err error) {
-	response, err = Request(siteConfig, methodGet, uriChannelsList, url.Values{
-		"lang":     []string{lang},
-		"sort":     []string{string(sort)},
-		"amount":   []string{strconv.FormatInt(amount, 10)},
-		"page":     []string{strconv.FormatInt(page, 10)},
-		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	})
+	response, err = Request(siteConfig, methodGet, uriChannelsList, channelsListValues(lang, page, sort, amount, groupId))
 	if err != nil {
 		return
 	}
@@ -25,3 +19,13 @@ func ChannelsList(
 	err = json.Unmarshal(response, &results)
 	return
 }
+
+func channelsListValues(lang string, page int64, sort SortBy, amount int64, groupId int64) url.Values {
+	return url.Values{
+		"lang":     []string{lang},
+		"sort":     []string{string(sort)},
+		"amount":   []string{strconv.FormatInt(amount, 10)},
+		"page":     []string{strconv.FormatInt(page, 10)},
+		"group_id": []string{strconv.FormatInt(groupId, 10)},
+	}
+}
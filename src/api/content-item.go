@@ -52,6 +52,15 @@ func ContentItem(siteConfig *types.Config, lang, slug string, id int64, omitRela
 
 func ContentItemRaw(siteConfig *types.Config, lang, slug string, id int64, omitRelatedForLink bool, relatedAmount int64, groupId int64,
 	related *RelatedParams) (response json.RawMessage, err error) {
+	response, err = Request(siteConfig, methodGet, uriContentItem, contentItemValues(lang, slug, id, omitRelatedForLink, relatedAmount, groupId, related))
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		log.Println(err, "slug: ", slug, "id: ", id)
+	}
+	return
+}
+
+func contentItemValues(lang, slug string, id int64, omitRelatedForLink bool, relatedAmount int64, groupId int64,
+	related *RelatedParams) url.Values {
 	params := url.Values{}
 	if related != nil {
 		if related.RandomizeLast > 0 {
@@ -100,9 +109,5 @@ func ContentItemRaw(siteConfig *types.Config, lang, slug string, id int64, omitR
 	params.Add("orfl", strconv.FormatBool(omitRelatedForLink))
 	params.Add("related", strconv.FormatInt(relatedAmount, 10))
 	params.Add("group_id", strconv.FormatInt(groupId, 10))
-	response, err = Request(siteConfig, methodGet, uriContentItem, params)
-	if err != nil && !strings.Contains(err.Error(), "not found") {
-		log.Println(err, "slug: ", slug, "id: ", id)
-	}
-	return
+	return params
 }
@@ -71,6 +71,11 @@ func Content(siteConfig *types.Config, params ContentParams) (results *types.Con
 }
 
 func ContentRaw(siteConfig *types.Config, params ContentParams) (rawResponse json.RawMessage, err error) {
+	rawResponse, err = Request(siteConfig, methodGet, uriContent, contentValues(params))
+	return
+}
+
+func contentValues(params ContentParams) url.Values {
 	var data = url.Values{}
 	if params.Ip != nil {
 		data.Add("ip", params.Ip.String())
@@ -134,6 +139,5 @@ func ContentRaw(siteConfig *types.Config, params ContentParams) (rawResponse jso
 	if len(params.AdditionalLanguages) > 0 {
 		data.Add("additional_languages", strings.Join(params.AdditionalLanguages, ","))
 	}
-	rawResponse, err = Request(siteConfig, methodGet, uriContent, data)
-	return
+	return data
 }
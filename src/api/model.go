@@ -11,12 +11,7 @@ import (
 func ModelInfo(
 	siteConfig *types.Config, lang string, id int64, slug string, groupId int64,
 ) (results *types.ModelResult, rawResponse json.RawMessage, err error) {
-	rawResponse, err = Request(siteConfig, methodGet, uriModel, url.Values{
-		"lang": []string{lang},
-		"slug": []string{slug},
-		"id":   []string{strconv.FormatInt(id, 10)},
-		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	})
+	rawResponse, err = Request(siteConfig, methodGet, uriModel, modelInfoValues(lang, id, slug, groupId))
 	if err != nil {
 		return
 	}
@@ -24,3 +19,12 @@ func ModelInfo(
 	err = json.Unmarshal(rawResponse, results)
 	return
 }
+
+func modelInfoValues(lang string, id int64, slug string, groupId int64) url.Values {
+	return url.Values{
+		"lang":     []string{lang},
+		"slug":     []string{slug},
+		"id":       []string{strconv.FormatInt(id, 10)},
+		"group_id": []string{strconv.FormatInt(groupId, 10)},
+	}
+}
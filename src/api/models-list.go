@@ -20,13 +20,17 @@ func ModelsList(siteConfig *types.Config, lang string, page int64, sort SortBy,
 }
 
 func ModelsListRaw(siteConfig *types.Config, lang string, page int64, sort SortBy, amount int64, searchQuery string, groupId int64) (response json.RawMessage, err error) {
-	response, err = Request(siteConfig, methodGet, uriModelsList, url.Values{
+	response, err = Request(siteConfig, methodGet, uriModelsList, modelsListValues(lang, page, sort, amount, searchQuery, groupId))
+	return
+}
+
+func modelsListValues(lang string, page int64, sort SortBy, amount int64, searchQuery string, groupId int64) url.Values {
+	return url.Values{
 		"lang":     []string{lang},
 		"sort":     []string{string(sort)},
 		"amount":   []string{strconv.FormatInt(amount, 10)},
 		"page":     []string{strconv.FormatInt(page, 10)},
 		"query":    []string{searchQuery},
 		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	})
-	return
-}
\ No newline at end of file
+	}
+}
@@ -9,11 +9,7 @@ import (
 )
 
 func RandomSearches(siteConfig *types.Config, lang string, amount int64, minSearches int64) (results []types.TopSearch, response json.RawMessage, err error) {
-	response, err = Request(siteConfig, methodGet, uriRandomSearches, url.Values{
-		"lang":         []string{lang},
-		"amount":       []string{strconv.FormatInt(amount, 10)},
-		"min_searches": []string{strconv.FormatInt(minSearches, 10)},
-	})
+	response, err = Request(siteConfig, methodGet, uriRandomSearches, randomSearchesValues(lang, amount, minSearches))
 	if err != nil {
 		log.Println(err)
 		return
@@ -28,3 +24,11 @@ func RandomSearches(siteConfig *types.Config, lang string, amount int64, minSear
 	results = result.Items
 	return
 }
+
+func randomSearchesValues(lang string, amount int64, minSearches int64) url.Values {
+	return url.Values{
+		"lang":         []string{lang},
+		"amount":       []string{strconv.FormatInt(amount, 10)},
+		"min_searches": []string{strconv.FormatInt(minSearches, 10)},
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// responseSchemas are the types api responses are unmarshalled into. In strict mode (development or
+// strict_api_decoding) responses are checked against them, so fields renamed on minion side don't get silently zero-filled.
+var responseSchemas = map[ApiUri]reflect.Type{
+	uriAutocomplete:    reflect.TypeOf(types.AutocompleteResults{}),
+	uriTimeframes:      reflect.TypeOf([]types.Timeframe{}),
+	uriOptions:         reflect.TypeOf(internal.Options{}),
+	uriContentItem:     reflect.TypeOf(types.ContentItemResult{}),
+	uriContentId:       reflect.TypeOf(ContentIdResult{}),
+	uriTopContent:      reflect.TypeOf(types.ContentResults{}),
+	uriCategory:        reflect.TypeOf(types.ContentResults{}),
+	uriCategoryInfo:    reflect.TypeOf(types.CategoryResult{}),
+	uriChannelInfo:     reflect.TypeOf(types.ChannelResult{}),
+	uriTopCategories:   reflect.TypeOf(types.CategoryResults{}),
+	uriContent:         reflect.TypeOf(types.ContentResults{}),
+	uriCategoriesList:  reflect.TypeOf(types.CategoryResults{}),
+	uriModelsList:      reflect.TypeOf(types.ModelResults{}),
+	uriModel:           reflect.TypeOf(types.ModelResult{}),
+	uriChannelsList:    reflect.TypeOf(types.ChannelResults{}),
+	uriCommentsGet:     reflect.TypeOf(types.CommentsResult{}),
+	uriCommentsReplies: reflect.TypeOf(types.CommentsResult{}),
+	uriRelated:         reflect.TypeOf([]types.RelatedItem{}),
+	uriLanguages:       reflect.TypeOf([]types.Language{}),
+	uriCountryGroups:   reflect.TypeOf([]types.CountryGroup{}),
+	uriTopSearches: reflect.TypeOf(struct {
+		Items []types.TopSearch `json:"items"`
+	}{}),
+	uriRandomSearches: reflect.TypeOf(struct {
+		Items []types.TopSearch `json:"items"`
+	}{}),
+}
+
+var schemaReported sync.Map // uri + field -> struct{}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// checkResponseSchema logs fields of the response unknown to the frontend and fields the frontend expects,
+// but minion didn't send. Every field is reported only once per endpoint.
+func checkResponseSchema(uri ApiUri, response json.RawMessage) {
+	if i := strings.IndexByte(string(uri), '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	t, ok := responseSchemas[uri]
+	if !ok {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(response, &value); err != nil {
+		return
+	}
+	compareSchema(uri, "", t, value)
+}
+
+func reportSchema(uri ApiUri, kind string, path string) {
+	if _, loaded := schemaReported.LoadOrStore(string(uri)+" "+kind+" "+path, struct{}{}); loaded {
+		return
+	}
+	log.Printf("api schema mismatch: %s, %s field %s (api version %s)", uri, kind, path, internal.ApiVersion(nil))
+}
+
+func compareSchema(uri ApiUri, path string, t reflect.Type, value interface{}) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if value == nil || t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) ||
+		t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := make(map[string]reflect.StructField)
+		collectSchemaFields(t, fields)
+		seen := make(map[string]bool, len(object))
+		for key, v := range object {
+			field, ok := fields[key]
+			seen[key] = ok
+			if !ok {
+				for name, f := range fields {
+					if strings.EqualFold(name, key) {
+						field, ok = f, true
+						seen[name] = true
+						break
+					}
+				}
+			}
+			if !ok {
+				reportSchema(uri, "unknown", path+key)
+				continue
+			}
+			compareSchema(uri, path+key+".", field.Type, v)
+		}
+		for name, field := range fields {
+			if seen[name] || strings.Contains(field.Tag.Get("json"), "omitempty") {
+				continue
+			}
+			reportSchema(uri, "missing", path+name)
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := value.([]interface{}); ok {
+			for _, item := range items {
+				compareSchema(uri, strings.TrimSuffix(path, ".")+"[].", t.Elem(), item)
+			}
+		}
+	case reflect.Map:
+		if object, ok := value.(map[string]interface{}); ok {
+			for _, v := range object {
+				compareSchema(uri, strings.TrimSuffix(path, ".")+"{}.", t.Elem(), v)
+			}
+		}
+	}
+}
+
+// collectSchemaFields collects json names of struct fields the way encoding/json sees them.
+func collectSchemaFields(t reflect.Type, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectSchemaFields(ft, fields)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = field
+		}
+	}
+}
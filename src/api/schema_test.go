@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaTestEmbedded struct {
+	Id int64 `json:"id"`
+}
+
+type schemaTestItem struct {
+	Y int `json:"y"`
+}
+
+type schemaTestResponse struct {
+	schemaTestEmbedded
+	Title    string                    `json:"title"`
+	Note     string                    `json:"note,omitempty"`
+	Name     string                    // no tag, json name is Name
+	Skipped  string                    `json:"-"`
+	Dated    time.Time                 `json:"dated"`
+	Items    []schemaTestItem          `json:"items"`
+	ByLang   map[string]schemaTestItem `json:"by_lang"`
+	internal string
+}
+
+// schemaReports returns fields reported for the uri.
+func schemaReports(uri ApiUri) []string {
+	var reports []string
+	schemaReported.Range(func(key, _ any) bool {
+		if k := key.(string); strings.HasPrefix(k, string(uri)+" ") {
+			reports = append(reports, strings.TrimPrefix(k, string(uri)+" "))
+		}
+		return true
+	})
+	sort.Strings(reports)
+	return reports
+}
+
+func TestCompareSchema(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{
+			name:     "matching response",
+			response: `{"id":1,"title":"t","Name":"n","dated":"2024-01-01T00:00:00Z","items":[{"y":1}],"by_lang":{"en":{"y":1}}}`,
+		},
+		{
+			name:     "unknown field",
+			response: `{"id":1,"title":"t","Name":"n","dated":"","items":[],"by_lang":{},"rating":5}`,
+			want:     []string{"unknown rating"},
+		},
+		{
+			name:     "missing field",
+			response: `{"id":1,"Name":"n","dated":"","items":[],"by_lang":{}}`,
+			want:     []string{"missing title"},
+		},
+		{
+			name:     "field names are matched case insensitive",
+			response: `{"ID":1,"TITLE":"t","name":"n","dated":"","items":[],"by_lang":{}}`,
+		},
+		{
+			name:     "ignored field is unknown",
+			response: `{"id":1,"title":"t","Name":"n","dated":"","items":[],"by_lang":{},"Skipped":"s"}`,
+			want:     []string{"unknown Skipped"},
+		},
+		{
+			name:     "nested slices and maps",
+			response: `{"id":1,"title":"t","Name":"n","dated":"","items":[{"y":1,"z":2}],"by_lang":{"en":{}}}`,
+			want:     []string{"missing by_lang{}.y", "unknown items[].z"},
+		},
+		{
+			name:     "null values are not checked",
+			response: `{"id":1,"title":"t","Name":"n","dated":null,"items":null,"by_lang":null}`,
+		},
+	}
+	for k, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := ApiUri("schema-test-" + string(rune('a'+k)))
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.response), &value); err != nil {
+				t.Fatal(err)
+			}
+			compareSchema(uri, "", reflect.TypeOf(schemaTestResponse{}), value)
+			if got := schemaReports(uri); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("reports = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckResponseSchema(t *testing.T) {
+	useTestConfig(t)
+	const uri ApiUri = "schema-test-check"
+	responseSchemas[uri] = reflect.TypeOf(schemaTestItem{})
+	defer delete(responseSchemas, uri)
+	tests := []struct {
+		name     string
+		uri      ApiUri
+		response string
+		want     []string
+	}{
+		{name: "query is stripped from uri", uri: uri + "?lang=en", response: `{"z":1}`, want: []string{"missing y", "unknown z"}},
+		{name: "field is reported once", uri: uri, response: `{"z":1}`, want: []string{"missing y", "unknown z"}},
+		{name: "invalid json is ignored", uri: uri, response: `{`, want: []string{"missing y", "unknown z"}},
+		{name: "unknown endpoint is not checked", uri: "schema-test-unknown", response: `{"z":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkResponseSchema(tt.uri, json.RawMessage(tt.response))
+			base := ApiUri(strings.SplitN(string(tt.uri), "?", 2)[0])
+			if got := schemaReports(base); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("reports = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
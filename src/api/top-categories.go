@@ -20,9 +20,13 @@ func TopCategories(siteConfig *types.Config, lang string, page int64, groupId in
 }
 
 func TopCategoriesRaw(siteConfig *types.Config, lang string, page int64, groupId int64) (response json.RawMessage, err error) {
-	return Request(siteConfig, methodGet, uriTopCategories, url.Values{
-		"lang": []string{lang},
-		"page": []string{strconv.FormatInt(page, 10)},
+	return Request(siteConfig, methodGet, uriTopCategories, topCategoriesValues(lang, page, groupId))
+}
+
+func topCategoriesValues(lang string, page int64, groupId int64) url.Values {
+	return url.Values{
+		"lang":     []string{lang},
+		"page":     []string{strconv.FormatInt(page, 10)},
 		"group_id": []string{strconv.FormatInt(groupId, 10)},
-	})
-}
\ No newline at end of file
+	}
+}
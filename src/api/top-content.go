@@ -21,6 +21,10 @@ func TopContent(siteConfig *types.Config, lang string, page int64, groupId int64
 }
 
 func TopContentRaw(siteConfig *types.Config, lang string, page int64, groupId int64, additionalLanguages []string) (response json.RawMessage, err error) {
+	return Request(siteConfig, methodGet, uriTopContent, topContentValues(lang, page, groupId, additionalLanguages))
+}
+
+func topContentValues(lang string, page int64, groupId int64, additionalLanguages []string) url.Values {
 	data := url.Values{
 		"lang":     []string{lang},
 		"page":     []string{strconv.FormatInt(page, 10)},
@@ -29,5 +33,5 @@ func TopContentRaw(siteConfig *types.Config, lang string, page int64, groupId in
 	if len(additionalLanguages) > 0 {
 		data.Add("additional_languages", strings.Join(additionalLanguages, ","))
 	}
-	return Request(siteConfig, methodGet, uriTopContent, data)
+	return data
 }
@@ -9,10 +9,7 @@ import (
 )
 
 func TopSearches(siteConfig *types.Config, lang string, amount int64) (results []types.TopSearch, response json.RawMessage, err error) {
-	response, err = Request(siteConfig, methodGet, uriTopSearches, url.Values{
-		"lang":   []string{lang},
-		"amount": []string{strconv.FormatInt(amount, 10)},
-	})
+	response, err = Request(siteConfig, methodGet, uriTopSearches, topSearchesValues(lang, amount))
 	if err != nil {
 		log.Println(err)
 		return
@@ -27,3 +24,10 @@ func TopSearches(siteConfig *types.Config, lang string, amount int64) (results [
 	results = result.Items
 	return
 }
+
+func topSearchesValues(lang string, amount int64) url.Values {
+	return url.Values{
+		"lang":   []string{lang},
+		"amount": []string{strconv.FormatInt(amount, 10)},
+	}
+}
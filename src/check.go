@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+	"sersh.com/totaltube/frontend/types"
+)
+
+var checkRouteParamRegex = regexp.MustCompile(`\{[^}]*}`)
+
+type checkRoute struct {
+	key      string
+	route    string
+	template string
+}
+
+// siteRoutes returns enabled routes of the site with the templates they are rendered with.
+func siteRoutes(config *types.Config) (routes []checkRoute) {
+	add := func(key, route, template string) {
+		if route = strings.TrimSpace(route); route != "" && route != "-" {
+			routes = append(routes, checkRoute{key: key, route: route, template: template})
+		}
+	}
+	r := config.Routes
+	for _, route := range builtinRoutes {
+		add(route.name, route.pattern(&r), route.template)
+		if route.pagination != nil {
+			add(route.name+"_pagination", route.pagination(&r), route.template)
+		}
+	}
+	add("sitemap", config.Sitemap.Route, "")
+	names := make([]string, 0, len(r.Custom))
+	for name := range r.Custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasSuffix(name, "_multilang") {
+			continue
+		}
+		add("custom."+name, r.Custom[name], "custom-"+strings.TrimSuffix(name, "_pagination"))
+	}
+	return
+}
+
+// checkSite returns the problems found in the site.
+func checkSite(sitePath string) (problems []string) {
+	configPath := filepath.Join(sitePath, "config.toml")
+	config, undecoded, err := internal.CheckConfig(configPath)
+	if err != nil {
+		return []string{"config.toml: " + err.Error()}
+	}
+	for _, key := range undecoded {
+		problems = append(problems, "config.toml: unknown key "+key)
+	}
+	templateErrs := site.CheckTemplates(sitePath)
+	for _, file := range sortedKeys(templateErrs) {
+		problems = append(problems, file+": "+templateErrs[file].Error())
+	}
+	extensionErrs := site.CheckExtensions(sitePath)
+	for _, file := range sortedKeys(extensionErrs) {
+		problems = append(problems, file+": "+extensionErrs[file].Error())
+	}
+	patterns := make(map[string]string)
+	for _, route := range siteRoutes(config) {
+		if route.template != "" && !site.TemplateExists(route.template, sitePath) {
+			problems = append(problems, fmt.Sprintf("routes.%s (%s): template %s not found", route.key, route.route, route.template))
+		}
+		if strings.HasPrefix(route.key, "custom.") && !strings.HasSuffix(route.key, "_pagination") {
+			extension := filepath.Join(sitePath, "extensions", "route-"+strings.TrimPrefix(route.key, "custom.")+".js")
+			if _, err := os.Stat(extension); err != nil {
+				problems = append(problems, fmt.Sprintf("routes.%s (%s): %s not found", route.key, route.route, filepath.Base(extension)))
+			}
+		}
+		for _, r := range strings.Split(route.route, ",") {
+			pattern := strings.TrimSuffix(checkRouteParamRegex.ReplaceAllString(strings.TrimSpace(r), "{}"), "/")
+			if existing, ok := patterns[pattern]; ok && existing != route.key {
+				problems = append(problems, fmt.Sprintf("routes.%s (%s) collides with routes.%s", route.key, route.route, existing))
+				continue
+			}
+			patterns[pattern] = route.key
+		}
+	}
+	for _, name := range sortedOptionNames(config.RouteOptions) {
+		if !routeOptionsNameKnown(config, name) {
+			problems = append(problems, "config.toml: route_options."+name+" doesn't match any route")
+		}
+		if timeout := time.Duration(config.RouteOptions[name].Timeout); timeout > maxRouteTimeout {
+			problems = append(problems, fmt.Sprintf("config.toml: route_options.%s.timeout %s is longer than %s",
+				name, timeout, maxRouteTimeout))
+		}
+	}
+	for _, alias := range config.Aliases {
+		if _, err := compileHostPattern(alias); err != nil {
+			problems = append(problems, "config.toml: "+err.Error())
+		}
+	}
+	if config.CanonicalHost != "" && config.CanonicalHost != "apex" && config.CanonicalHost != "www" {
+		problems = append(problems, "config.toml: canonical_host must be apex or www, got "+config.CanonicalHost)
+	}
+	for _, ip := range config.Maintenance.AllowIps {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			problems = append(problems, "config.toml: maintenance.allow_ips: bad ip "+ip)
+		}
+	}
+	return
+}
+
+// routeOptionsNameKnown checks if [route_options.<name>] is set up for the existing route.
+func routeOptionsNameKnown(config *types.Config, name string) bool {
+	if name == "sitemap" || name == "toplist_data" {
+		return true
+	}
+	for _, route := range builtinRoutes {
+		if route.name == name {
+			return true
+		}
+	}
+	_, ok := config.Routes.Custom[name]
+	return ok && !strings.HasSuffix(name, "_multilang") && !strings.HasSuffix(name, "_pagination")
+}
+
+func sortedOptionNames(m map[string]types.RouteOptions) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m map[string]error) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check lints templates, routes, config and extensions of all sites or of the one site and exits with non-zero code
+// if there are problems.
+func Check() {
+	var sitePaths []string
+	if CLI.Check.Site != "" {
+		sitePaths = []string{filepath.Join(internal.Config.Frontend.SitesPath, internal.NormalizeHost(CLI.Check.Site))}
+	} else {
+		matches, err := filepath.Glob(filepath.Join(internal.Config.Frontend.SitesPath, "*", "config.toml"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, m := range matches {
+			sitePaths = append(sitePaths, filepath.Dir(m))
+		}
+	}
+	site.InitPongo2()
+	total := 0
+	for _, sitePath := range sitePaths {
+		problems := checkSite(sitePath)
+		total += len(problems)
+		if len(problems) == 0 {
+			fmt.Println(filepath.Base(sitePath) + ": ok")
+			continue
+		}
+		fmt.Println(filepath.Base(sitePath) + ":")
+		for _, problem := range problems {
+			fmt.Println("  " + problem)
+		}
+	}
+	if total > 0 {
+		fmt.Printf("%d problems found\n", total)
+		os.Exit(1)
+	}
+}
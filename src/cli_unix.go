@@ -4,9 +4,17 @@
 package main
 
 var CLI struct {
-	Install     struct{} `cmd:"" help:"install totaltube-frontend"`
-	Start       struct{} `cmd:"" help:"Start totaltube-frontend" hidden:""`
-	Child       struct{} `cmd:"" help:"Internal command to spawn the worker" hidden:""`
-	Config      string   `name:"config" short:"c" type:"path" help:"location of totaltube frontend config.toml" env:"TTF_CONFIG_PATH" default:"/var/lib/totaltube-frontend/config.toml" predictor:"toml"`
-	RebuildSass bool     ``
+	Install struct{}  `cmd:"" help:"install totaltube-frontend"`
+	Start   struct{}  `cmd:"" help:"Start totaltube-frontend" hidden:""`
+	Child   struct{}  `cmd:"" help:"Internal command to spawn the worker" hidden:""`
+	Export  ExportCmd `cmd:"" help:"Export site to static html files"`
+	Check   struct {
+		Site string `arg:"" optional:"" help:"hostname of the site to check, all sites by default"`
+	} `cmd:"" help:"Check templates, routes, config and extensions of the sites"`
+	TestTemplates TestTemplatesCmd `cmd:"" help:"Render test fixtures of the sites and compare them with golden html files"`
+	Preview       PreviewCmd       `cmd:"" help:"Print signed link to preview templates-draft of the site"`
+	Promote       PromoteCmd       `cmd:"" help:"Replace live templates of the site with templates-draft"`
+	History       HistoryCmd       `cmd:"" help:"List, diff and roll back versions of site configs and templates"`
+	Config        string           `name:"config" short:"c" type:"path" help:"location of totaltube frontend config.toml" env:"TTF_CONFIG_PATH" default:"/var/lib/totaltube-frontend/config.toml" predictor:"toml"`
+	RebuildSass   bool             ``
 }
@@ -24,16 +24,13 @@ func GetSEBotRanges() (ranges []string, err error) {
 			req, _ = http.NewRequest("GET", u, nil)
 			req.Header.Set("Accept", "application/json")
 			req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 6.1; WOW64; rv:54.0) Gecko/20100101 Firefox/54.0")
-			req.Close = true
 			var resp *http.Response
-			client := &http.Client{
-				Timeout: time.Second * 60,
-			}
-			resp, err = client.Do(req)
+			resp, err = helpers.HttpClient(time.Second * 60).Do(req)
 			if err != nil {
 				log.Println(err)
 				return
 			}
+			defer resp.Body.Close()
 
 			var bt []byte
 			bt, err = io.ReadAll(resp.Body)
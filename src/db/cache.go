@@ -159,8 +159,11 @@ func readFromBadgerCache(key, expireKey []byte) (data []byte, found bool, expire
 // чтобы понимать, когда данные «начнут считаться устаревшими» внутри приложения.
 func storeToBadgerCache(key, expireKey []byte, data []byte, timeout, extendedTime time.Duration) error {
 	return bdb.Update(func(txn *badger.Txn) error {
-		// TTL в Badger будет timeout + extendedTime
+		// TTL в Badger будет timeout + extendedTime (+ keep_stale для offline режима)
 		ttl := timeout + extendedTime
+		if internal.Config.Offline.Enable {
+			ttl += time.Duration(internal.Config.Offline.KeepStale)
+		}
 
 		entry := badger.NewEntry(key, data).WithTTL(ttl)
 		if err := txn.SetEntry(entry); err != nil {
@@ -175,6 +178,55 @@ func storeToBadgerCache(key, expireKey []byte, data []byte, timeout, extendedTim
 	})
 }
 
+// CacheState returns "hit", "stale" or "miss" for the cache key and the time left until it expires.
+// Used by debug toolbar before the cache is read.
+func CacheState(cacheKey string) (status string, ttl time.Duration) {
+	expire, found := CacheExpire(cacheKey)
+	if !found {
+		return "miss", 0
+	}
+	if expire.IsZero() {
+		return "hit", 0
+	}
+	if ttl = time.Until(expire); ttl <= 0 {
+		return "stale", ttl
+	}
+	return "hit", ttl
+}
+
+// CacheFresh reports if GetCachedTimeout for the key returns cached data without calling recreate function.
+func CacheFresh(cacheKey string, bypassCache bool) bool {
+	if bypassCache && !internal.IsOffline() {
+		return false
+	}
+	switch status, _ := CacheState(cacheKey); status {
+	case "hit":
+		return true
+	case "stale":
+		return internal.IsOffline()
+	}
+	return false
+}
+
+// CacheExpire returns the time the cached data of the key becomes stale. Zero time is returned for data without timeout.
+func CacheExpire(cacheKey string) (expire time.Time, found bool) {
+	_ = bdb.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(cachePrefix + cacheKey)); err != nil {
+			return err
+		}
+		found = true
+		item, err := txn.Get([]byte(cachePrefix + "_exp_" + cacheKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			expire, err = time.Parse(time.RFC3339, string(val))
+			return err
+		})
+	})
+	return
+}
+
 // GetCachedTimeout gets cached data with timeout
 func GetCachedTimeout(
 	cacheKey string,
@@ -195,18 +247,22 @@ func GetCachedTimeout(
 		<-update.done // Ждём, пока она закончит
 
 		// Затем пытаемся прочитать из кэша (или пересоздать, если нет)
-		if !bypassCache {
+		var stale []byte
+		if !bypassCache || internal.IsOffline() {
 			data, found, expired, err := readFromBadgerCache(key, expireKey)
 			if err != nil {
 				return nil, err
 			}
-			if found && !expired {
-				// У нас есть валидные данные — сразу возвращаем
+			if found && (!expired || internal.IsOffline()) {
+				// У нас есть валидные данные (или мы в offline режиме) — сразу возвращаем
 				return data, nil
 			}
+			if found {
+				stale = data
+			}
 		}
 		// Если ничего нет — пересоздаем
-		return recreateAndStore(cacheKey, timeout, extendedTimeout, recreate)
+		return recreateOrStale(cacheKey, timeout, extendedTimeout, recreate, stale)
 	}
 
 	// Мы "первые" — берём на себя обновление
@@ -216,18 +272,37 @@ func GetCachedTimeout(
 		cacheUpdates.Delete(cacheKey)
 	}()
 
-	// 2. Сразу читаем из кэша, если bypassCache = false
-	if !bypassCache {
+	// 2. Сразу читаем из кэша, если bypassCache = false. В offline режиме отдаём любой найденный вариант.
+	var stale []byte
+	if !bypassCache || internal.IsOffline() {
 		data, found, expired, _ := readFromBadgerCache(key, expireKey)
-		if found && !expired {
+		if found && (!expired || internal.IsOffline()) {
 			// Кэш актуален
 			return data, nil
 		}
 		// Иначе надо пересоздавать (либо не найден, либо просрочен)
+		if found {
+			stale = data
+		}
 	}
 
 	// 3. Пересоздаём и записываем в кэш
-	return recreateAndStore(cacheKey, timeout, extendedTimeout, recreate)
+	return recreateOrStale(cacheKey, timeout, extendedTimeout, recreate, stale)
+}
+
+// recreateOrStale recreates the cache, but returns stale data if recreate failed and offline mode is enabled.
+func recreateOrStale(
+	cacheKey string,
+	timeout, extendedTimeout time.Duration,
+	recreate func() ([]byte, error),
+	stale []byte,
+) ([]byte, error) {
+	result, err := recreateAndStore(cacheKey, timeout, extendedTimeout, recreate)
+	if err != nil && stale != nil && internal.Config.Offline.Enable && err.Error() != "custom response" &&
+		!strings.Contains(err.Error(), "not found") {
+		return stale, nil
+	}
+	return result, err
 }
 
 func recreateAndStore(
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// Version history of site files. Contents are stored once by sha256, versions are stored by host, file and time.
+const (
+	historyBlobPrefix    = "hb_"
+	historyVersionPrefix = "hv_"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// FileVersion is a saved state of the site file. Deleted version means the file was removed.
+type FileVersion struct {
+	File    string    `json:"file"`
+	Time    time.Time `json:"time"`
+	Hash    string    `json:"hash"`
+	Size    int       `json:"size"`
+	Deleted bool      `json:"deleted,omitempty"`
+}
+
+func historyFilePrefix(host, file string) []byte {
+	return []byte(historyVersionPrefix + host + "\x00" + file + "\x00")
+}
+
+func historyVersionKey(host, file string, t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(historyFilePrefix(host, file), uint64(t.UnixNano()))
+}
+
+// SaveFileVersion saves the content of the site file to the history if it differs from the last saved version.
+func SaveFileVersion(host, file string, content []byte, deleted bool) (saved bool, err error) {
+	if bdb == nil {
+		// cli commands without database don't keep the history
+		return false, nil
+	}
+	versions, err := FileVersions(host, file)
+	if err != nil {
+		return false, err
+	}
+	v := FileVersion{File: file, Time: time.Now(), Size: len(content), Deleted: deleted}
+	if !deleted {
+		sum := sha256.Sum256(content)
+		v.Hash = hex.EncodeToString(sum[:])
+	}
+	if len(versions) > 0 && versions[0].Hash == v.Hash && versions[0].Deleted == v.Deleted {
+		return false, nil
+	}
+	if len(versions) == 0 && deleted {
+		return false, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+	err = bdb.Update(func(txn *badger.Txn) error {
+		if !deleted {
+			if err := txn.Set([]byte(historyBlobPrefix+v.Hash), content); err != nil {
+				return err
+			}
+		}
+		return txn.Set(historyVersionKey(host, file, v.Time), data)
+	})
+	return err == nil, err
+}
+
+// FileVersions returns saved versions of the site file, or of all files if file is empty, the newest first.
+func FileVersions(host, file string) (versions []FileVersion, err error) {
+	prefix := []byte(historyVersionPrefix + host + "\x00")
+	if file != "" {
+		prefix = historyFilePrefix(host, file)
+	}
+	err = bdb.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var v FileVersion
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &v)
+			}); err != nil {
+				return err
+			}
+			versions = append(versions, v)
+		}
+		return nil
+	})
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Time.After(versions[j].Time)
+	})
+	return
+}
+
+// FileVersionAt returns the version of the site file at the time.
+func FileVersionAt(host, file string, t time.Time) (FileVersion, error) {
+	versions, err := FileVersions(host, file)
+	if err != nil {
+		return FileVersion{}, err
+	}
+	for _, v := range versions {
+		if !v.Time.After(t) {
+			return v, nil
+		}
+	}
+	return FileVersion{}, ErrVersionNotFound
+}
+
+// FindFileVersion returns the version of the site file by hash prefix.
+func FindFileVersion(host, file, hashPrefix string) (FileVersion, error) {
+	versions, err := FileVersions(host, file)
+	if err != nil {
+		return FileVersion{}, err
+	}
+	for _, v := range versions {
+		if hashPrefix != "" && strings.HasPrefix(v.Hash, hashPrefix) {
+			return v, nil
+		}
+	}
+	return FileVersion{}, ErrVersionNotFound
+}
+
+// VersionContent returns the saved content by its hash.
+func VersionContent(hash string) (content []byte, err error) {
+	err = bdb.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(historyBlobPrefix + hash))
+		if err != nil {
+			return err
+		}
+		content, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		err = ErrVersionNotFound
+	}
+	return
+}
@@ -154,6 +154,12 @@ func InitDB() {
 	}
 }
 
+// InitMemoryDB opens in-memory database without background jobs. Used by tests.
+func InitMemoryDB() (err error) {
+	bdb, err = badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLoggingLevel(badger.WARNING))
+	return
+}
+
 // BeforeClose closes the database before the server is closed
 func BeforeClose() {
 	if bdb != nil {
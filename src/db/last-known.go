@@ -0,0 +1,34 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const lastKnownPrefix = "lk_"
+
+// SaveLastKnown stores the last successful api result without expiration, so it can be used
+// on startup when minion is not available.
+func SaveLastKnown(name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bdb.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(lastKnownPrefix+name), data)
+	})
+}
+
+// LoadLastKnown loads the result stored by SaveLastKnown.
+func LoadLastKnown(name string, result interface{}) error {
+	return bdb.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(lastKnownPrefix + name))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, result)
+		})
+	})
+}
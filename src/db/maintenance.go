@@ -0,0 +1,48 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+const maintenancePrefix = "mt_"
+
+// SaveMaintenance stores maintenance mode of the site set with admin api, nil removes it.
+func SaveMaintenance(host string, maintenance *types.ConfigMaintenance) error {
+	if maintenance == nil {
+		return bdb.Update(func(txn *badger.Txn) error {
+			return txn.Delete([]byte(maintenancePrefix + host))
+		})
+	}
+	data, err := json.Marshal(maintenance)
+	if err != nil {
+		return err
+	}
+	return bdb.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(maintenancePrefix+host), data)
+	})
+}
+
+// LoadMaintenance loads maintenance mode of the site set with admin api, nil if it's not set.
+func LoadMaintenance(host string) (maintenance *types.ConfigMaintenance, err error) {
+	if bdb == nil {
+		return nil, nil
+	}
+	err = bdb.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(maintenancePrefix + host))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			maintenance = new(types.ConfigMaintenance)
+			return json.Unmarshal(val, maintenance)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	return
+}
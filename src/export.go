@@ -0,0 +1,365 @@
+package main
+
+import (
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sersh.com/totaltube/frontend/api"
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/geoip"
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+	"sersh.com/totaltube/frontend/types"
+)
+
+type ExportCmd struct {
+	Site     string `name:"site" required:"" help:"hostname of the site to export"`
+	Out      string `name:"out" required:"" type:"path" help:"output directory"`
+	Pages    int64  `name:"pages" default:"10" help:"max page number of paginated listings"`
+	MaxPages int    `name:"max-pages" default:"10000" help:"max amount of exported pages"`
+	Db       string `name:"db" type:"path" help:"cache database directory. Temporary one is used by default, as the database of running server is locked"`
+}
+
+var exportLinkRegex = regexp.MustCompile(`(?i)(href|src|action)=("|')([^"']*)("|')`)
+var exportLocRegex = regexp.MustCompile(`<loc>([^<]+)</loc>`)
+var exportRouteParamRegex = regexp.MustCompile(`\{([a-zA-Z_]+)(:[^}]+)?}`)
+
+type exporter struct {
+	handler     http.Handler
+	host        string
+	config      *types.Config
+	out         string
+	public      string // public directory of the site, its files are copied as is
+	maxPage     int64
+	maxPages    int
+	pagination  []*regexp.Regexp
+	outRoute    *regexp.Regexp
+	queue       []string
+	seen        map[string]bool
+	exported    map[string]string // url -> file
+	redirects   map[string]string // url -> target url
+	contentType map[string]string
+}
+
+// Export renders the site through the real handlers and writes it to disk as static files.
+func Export() {
+	opts := CLI.Export
+	host := internal.NormalizeHost(opts.Site)
+	sitePath := filepath.Join(internal.Config.Frontend.SitesPath, host)
+	configPath := filepath.Join(sitePath, "config.toml")
+	if _, err := os.Stat(configPath); err != nil {
+		log.Fatalln("Can't find site", host, "in", internal.Config.Frontend.SitesPath, err)
+	}
+	geoipPath := internal.Config.Database.Path
+	if opts.Db == "" {
+		tmp, err := os.MkdirTemp("", "totaltube-export-")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer os.RemoveAll(tmp)
+		opts.Db = tmp
+	}
+	internal.Config.Database.Path = opts.Db
+	internal.Config.Database.RestoreFromBackup = false
+	internal.Config.Database.BackupPath = ""
+	log.Println("Initializing database...")
+	db.InitDB()
+	defer db.BeforeClose()
+	initLastKnownOptions()
+	log.Println("Initializing languages...")
+	initLanguages()
+	log.Println("Initializing pongo templates...")
+	site.InitPongo2()
+	helpers.InitMinifier()
+	initCountryGroups()
+	geoip.InitGeoIP(geoipPath, internal.Config.General.GeoipUrl)
+	e := &exporter{
+		handler:     InitRouter(),
+		host:        host,
+		config:      internal.GetConfig(configPath, api.UpdateConfigRetry),
+		out:         opts.Out,
+		public:      filepath.Join(sitePath, "public"),
+		maxPage:     opts.Pages,
+		maxPages:    opts.MaxPages,
+		seen:        make(map[string]bool),
+		exported:    make(map[string]string),
+		redirects:   make(map[string]string),
+		contentType: make(map[string]string),
+	}
+	e.init()
+	if err := os.MkdirAll(e.out, 0755); err != nil {
+		log.Fatalln(err)
+	}
+	if err := copyDir(e.public, e.out); err != nil {
+		log.Println("Can't copy public files:", err)
+	}
+	e.crawl()
+	e.rewrite()
+	log.Printf("Exported %d pages of %s to %s\n", len(e.exported), host, e.out)
+}
+
+// routeRegex converts chi route pattern to regex. Captured group is the page number if the route has {page}.
+func routeRegex(route string) *regexp.Regexp {
+	route = strings.TrimSpace(route)
+	if route == "" || route == "-" {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range exportRouteParamRegex.FindAllStringSubmatchIndex(route, -1) {
+		b.WriteString(regexp.QuoteMeta(route[last:m[0]]))
+		if route[m[2]:m[3]] == "page" {
+			b.WriteString("([0-9]+)")
+		} else {
+			b.WriteString("[^/]+")
+		}
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(route[last:]))
+	b.WriteString("/?$")
+	r, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+func (e *exporter) init() {
+	routes := e.config.Routes
+	for _, route := range []string{
+		routes.TopCategoriesPagination, routes.TopContentPagination, routes.PopularPagination, routes.NewPagination,
+		routes.LongPagination, routes.ModelPagination, routes.ModelsPagination, routes.CategoryPagination,
+		routes.ChannelPagination, routes.SearchPagination,
+	} {
+		if r := routeRegex(route); r != nil {
+			e.pagination = append(e.pagination, r)
+		}
+	}
+	for name, route := range routes.Custom {
+		if strings.HasSuffix(name, "_pagination") {
+			if r := routeRegex(route); r != nil {
+				e.pagination = append(e.pagination, r)
+			}
+		}
+	}
+	e.outRoute = routeRegex(routes.Out)
+	e.add("/")
+	for _, route := range []string{routes.TopCategories, routes.TopContent, routes.Popular, routes.New, routes.Long, routes.Models} {
+		if route != "" && route != "-" && !strings.Contains(route, "{") {
+			e.add(route)
+		}
+	}
+	for name, route := range routes.Custom {
+		if !strings.HasSuffix(name, "_pagination") && !strings.HasSuffix(name, "_multilang") && !strings.Contains(route, "{") {
+			e.add(route)
+		}
+	}
+	if e.config.Sitemap.Route != "" {
+		e.add(e.config.Sitemap.Route)
+	}
+}
+
+// normalize returns site relative url for the link or empty string if the link is not exportable.
+func (e *exporter) normalize(link string, base string) string {
+	link = html.UnescapeString(strings.TrimSpace(link))
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "data:") {
+		return ""
+	}
+	baseUrl, _ := url.Parse("http://" + e.host + base)
+	u, err := baseUrl.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || internal.NormalizeHost(u.Hostname()) != e.host {
+		return ""
+	}
+	u.Fragment = ""
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		return p + "?" + u.RawQuery
+	}
+	return p
+}
+
+func (e *exporter) exportable(u string) bool {
+	p, _, _ := strings.Cut(u, "?")
+	if e.outRoute != nil && e.outRoute.MatchString(p) {
+		// /c links are kept as plain links
+		return false
+	}
+	if fi, err := os.Stat(filepath.Join(e.public, filepath.FromSlash(p))); err == nil && fi.Mode().IsRegular() {
+		// static file from public directory
+		return false
+	}
+	for _, r := range e.pagination {
+		if m := r.FindStringSubmatch(p); len(m) > 1 {
+			page, _ := strconv.ParseInt(m[1], 10, 64)
+			return page <= e.maxPage
+		}
+	}
+	return true
+}
+
+func (e *exporter) add(u string) {
+	if u == "" || e.seen[u] || !e.exportable(u) {
+		return
+	}
+	e.seen[u] = true
+	e.queue = append(e.queue, u)
+}
+
+// fileName returns the path of exported file for url relative to out directory.
+func (e *exporter) fileName(u string, contentType string) string {
+	p, query, _ := strings.Cut(u, "?")
+	p = strings.Trim(p, "/")
+	name := "index.html"
+	if ext := path.Ext(p); ext == ".html" || (ext != "" && !strings.HasPrefix(contentType, "text/html")) {
+		name = path.Base(p)
+		p = path.Dir(p)
+	} else if strings.Contains(contentType, "xml") {
+		name = "index.xml"
+	} else if strings.Contains(contentType, "json") {
+		name = "index.json"
+	}
+	if query != "" {
+		ext := path.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "-" + helpers.Md5Hash(query)[:10] + ext
+	}
+	if p == "" || p == "." {
+		return name
+	}
+	return path.Join(p, name)
+}
+
+func (e *exporter) crawl() {
+	for len(e.queue) > 0 && len(e.exported) < e.maxPages {
+		u := e.queue[0]
+		e.queue = e.queue[1:]
+		req := httptest.NewRequest(http.MethodGet, "http://"+e.host+u, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+		req.RemoteAddr = "127.0.0.1:1"
+		rec := httptest.NewRecorder()
+		e.handler.ServeHTTP(rec, req)
+		if rec.Code >= 300 && rec.Code < 400 {
+			if target := e.normalize(rec.Header().Get("Location"), u); target != "" {
+				e.redirects[u] = target
+				e.add(target)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			log.Println("export:", u, "returned status", rec.Code)
+			continue
+		}
+		contentType := rec.Header().Get("Content-Type")
+		file := e.fileName(u, contentType)
+		fullPath := filepath.Join(e.out, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := os.WriteFile(fullPath, rec.Body.Bytes(), 0644); err != nil {
+			log.Println(err)
+			continue
+		}
+		e.exported[u] = file
+		e.contentType[u] = contentType
+		body := rec.Body.String()
+		for _, m := range exportLinkRegex.FindAllStringSubmatch(body, -1) {
+			e.add(e.normalize(m[3], u))
+		}
+		for _, m := range exportLocRegex.FindAllStringSubmatch(body, -1) {
+			e.add(e.normalize(m[1], u))
+		}
+	}
+}
+
+// link returns the link to the exported copy of url.
+func (e *exporter) link(u string) (string, bool) {
+	for i := 0; i < 10; i++ {
+		target, ok := e.redirects[u]
+		if !ok {
+			break
+		}
+		u = target
+	}
+	file, ok := e.exported[u]
+	if !ok {
+		return "", false
+	}
+	if path.Base(file) == "index.html" {
+		return "/" + strings.TrimSuffix(file, "index.html"), true
+	}
+	return "/" + file, true
+}
+
+// rewrite makes links of exported html pages point to exported files.
+func (e *exporter) rewrite() {
+	for u, file := range e.exported {
+		if !strings.HasPrefix(e.contentType[u], "text/html") {
+			continue
+		}
+		fullPath := filepath.Join(e.out, filepath.FromSlash(file))
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		base := u
+		rewritten := exportLinkRegex.ReplaceAllStringFunc(string(data), func(s string) string {
+			m := exportLinkRegex.FindStringSubmatch(s)
+			if link, ok := e.link(e.normalize(m[3], base)); ok {
+				return m[1] + "=" + m[2] + link + m[4]
+			}
+			return s
+		})
+		if err = os.WriteFile(fullPath, []byte(rewritten), 0644); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if _, err = io.Copy(out, in); err != nil {
+			return errors.Wrap(err, "copying "+p)
+		}
+		return nil
+	})
+}
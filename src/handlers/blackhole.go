@@ -14,7 +14,7 @@ var Blackhole = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	ip := r.Context().Value(types.ContextKeyIp).(string)
 	userAgent := r.Header.Get("User-Agent")
 	referer := r.Header.Get("Referer")
-	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	OutputError(w, r, http.StatusForbidden, "blackhole")
 	isSeBot, err := db.CheckIfSeBot(ip)
 	if err != nil {
 		log.Println(err)
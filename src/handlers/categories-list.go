@@ -9,7 +9,7 @@ import (
 	"sersh.com/totaltube/frontend/types"
 )
 
-func getCategoriesListFunc(config *types.Config, langId string, defaultAmount int64, groupId int64) func(args ...any) *types.CategoryResults {
+func getCategoriesListFunc(batch *api.Batch, langId string, defaultAmount int64, groupId int64) func(args ...any) *types.CategoryResults {
 	return func(args ...any) *types.CategoryResults {
 		parsingName := true
 		var amount = defaultAmount
@@ -35,11 +35,11 @@ func getCategoriesListFunc(config *types.Config, langId string, defaultAmount in
 				amount, _ = strconv.ParseInt(val, 10, 64)
 			}
 		}
-		results, _, err := api.CategoriesList(config, langId, page, sortBy, amount, groupId)
+		results, err := batch.CategoriesList(langId, page, sortBy, amount, groupId).Remember().Result()
 		if err != nil {
 			log.Println("can't get categories list:", err)
 			return nil
 		}
-		return results
+		return results.(*types.CategoryResults)
 	}
 }
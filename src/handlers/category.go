@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -153,13 +154,37 @@ var Category = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	parsed, err := site.ParseTemplate("category", path, config, customContext, nocache, cacheKey, pageTtl,
 		func() (pongo2.Context, error) {
 			ctx := pongo2.Context{}
-			// getting category information from cache or from api
+			// all api requests needed for the page, which are not cached, are sent in one round trip
 			categoryInfoCacheKey := fmt.Sprintf("in:cinfo:%d:%s:%s", categoryId, categorySlug, langId)
+			batch := pageBatch(customContext)
+			categoryInfoCall := batch.CategoryInfo(langId, categoryId, categorySlug).
+				Cached(db.CacheFresh(categoryInfoCacheKey, nocache))
+			var contentCall *api.BatchCall
+			if filtered {
+				contentCall = batch.Content(api.ContentParams{
+					Lang:         langId,
+					Page:         page,
+					Ip:           ip,
+					CategoryId:   categoryId,
+					CategorySlug: categorySlug,
+					ChannelId:    channelId,
+					ChannelSlug:  channelSlug,
+					ModelId:      modelId,
+					ModelSlug:    modelSlug,
+					Sort:         api.SortBy(sortBy),
+					Timeframe:    sortByViewsTimeframe,
+					DurationGte:  durationFrom,
+					DurationLt:   durationTo,
+					UserAgent:    userAgent,
+					Amount:       amount,
+				})
+			} else {
+				contentCall = batch.Category(langId, categoryId, categorySlug, page, groupId, []string{})
+			}
+			contentCall.Cached(db.CacheFresh(cacheKey+":data", nocache))
+			// getting category information from cache or from api
 			categoryInfoCacheTtl := time.Hour*24 + time.Duration(rand.Intn(3600*6))*time.Second
-			categoryInfoCached, err := db.GetCachedTimeout(categoryInfoCacheKey, categoryInfoCacheTtl, time.Hour*4, func() ([]byte, error) {
-				_, rawResponse, err := api.CategoryInfo(config, langId, categoryId, categorySlug)
-				return rawResponse, err
-			}, nocache)
+			categoryInfoCached, err := db.GetCachedTimeout(categoryInfoCacheKey, categoryInfoCacheTtl, time.Hour*4, categoryInfoCall.Raw, nocache)
 			if err != nil {
 				if !strings.Contains(err.Error(), "favicon.ico") {
 					log.Println(err, config.Hostname, ip)
@@ -172,43 +197,16 @@ var Category = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				log.Println(err, config.Hostname)
 				return ctx, err
 			}
-			var results = new(types.ContentResults)
-			if filtered {
-				var response []byte
-				response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), func() ([]byte, error) {
-					return api.ContentRaw(config, api.ContentParams{
-						Lang:         langId,
-						Page:         page,
-						Ip:           ip,
-						CategoryId:   categoryId,
-						CategorySlug: categorySlug,
-						ChannelId:    channelId,
-						ChannelSlug:  channelSlug,
-						ModelId:      modelId,
-						ModelSlug:    modelSlug,
-						Sort:         api.SortBy(sortBy),
-						Timeframe:    sortByViewsTimeframe,
-						DurationGte:  durationFrom,
-						DurationLt:   durationTo,
-						UserAgent:    userAgent,
-						Amount:       amount,
-					})
-				}, nocache)
-				if err != nil {
-					return ctx, err
-				}
-				err = json.Unmarshal(response, results)
-			} else {
+			if !filtered {
 				ctx["count"] = true
-				var response []byte
-				response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), func() ([]byte, error) {
-					return api.CategoryRaw(config, langId, categoryId, categorySlug, page, groupId, []string{})
-				}, nocache)
-				if err != nil {
-					return ctx, err
-				}
-				err = json.Unmarshal(response, results)
 			}
+			var results = new(types.ContentResults)
+			var response []byte
+			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), contentCall.Raw, nocache)
+			if err != nil {
+				return ctx, err
+			}
+			err = json.Unmarshal(response, results)
 			if err != nil {
 				return ctx, err
 			}
@@ -255,7 +253,7 @@ var Category = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	render.HTML(w, r, string(parsed))
 })
 
-func getCategoryFunc(config *types.Config, langId string) func(args ...interface{}) *types.CategoryResult {
+func getCategoryFunc(batch *api.Batch, langId string) func(args ...interface{}) *types.CategoryResult {
 	return func(args ...interface{}) *types.CategoryResult {
 		parsingName := true
 		var categoryId int64
@@ -282,15 +280,15 @@ func getCategoryFunc(config *types.Config, langId string) func(args ...interface
 			log.Println("error getting category content - need to set category_id or category_slug param")
 			return nil
 		}
-		if results, _, err := api.CategoryInfo(config, langId, categoryId, categorySlug); err != nil {
+		if results, err := batch.CategoryInfo(langId, categoryId, categorySlug).Remember().Result(); err != nil {
 			log.Println("error getting category content: ", err)
 			return nil
 		} else {
-			return results
+			return results.(*types.CategoryResult)
 		}
 	}
 }
-func getCategoryTopFunc(config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.ContentResults {
+func getCategoryTopFunc(batch *api.Batch, config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.ContentResults {
 	return func(args ...interface{}) *types.ContentResults {
 		parsingName := true
 		var categoryId int64
@@ -322,17 +320,22 @@ func getCategoryTopFunc(config *types.Config, langId string, groupId int64) func
 			log.Println("error getting top category content - need to set category_id or category_slug param")
 			return nil
 		}
-		if results, err := api.Category(config, langId, categoryId, categorySlug, page, groupId, []string{}); err != nil {
+		if result, err := batch.Category(langId, categoryId, categorySlug, page, groupId, []string{}).Remember().Result(); err != nil {
 			log.Println("error getting category top content: ", err)
 			return nil
 		} else {
+			results := result.(*types.ContentResults)
 			if page == 1 {
 				randomizeRatio := config.General.RandomizeRatio
 				if randomizeRatio < 0 {
 					randomizeRatio = internal.Config.General.RandomizeRatio
 				}
 				if randomizeRatio > 0 {
-					helpers.RandomizeItems(results.Items, randomizeRatio)
+					// result of the batch call is shared by all calls with the same params
+					randomized := *results
+					randomized.Items = slices.Clone(results.Items)
+					helpers.RandomizeItems(randomized.Items, randomizeRatio)
+					return &randomized
 				}
 			}
 			return results
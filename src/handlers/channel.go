@@ -97,13 +97,32 @@ var Channel = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	parsed, err := site.ParseTemplate("channel", path, config, customContext, nocache, cacheKey, time.Duration(cacheTtl),
 		func() (pongo2.Context, error) {
 			ctx := pongo2.Context{}
-			// getting category information from cache or from api
+			// all api requests needed for the page, which are not cached, are sent in one round trip
 			channelInfoCacheKey := fmt.Sprintf("in:chinfo:%d:%s:%s", channelId, channelSlug, langId)
+			batch := pageBatch(customContext)
+			channelInfoCall := batch.ChannelInfo(langId, channelId, channelSlug).
+				Cached(db.CacheFresh(channelInfoCacheKey, nocache))
+			contentCall := batch.Content(api.ContentParams{
+				Lang:         langId,
+				Page:         page,
+				CategoryId:   categoryId,
+				CategorySlug: categorySlug,
+				ChannelId:    channelId,
+				ChannelSlug:  channelSlug,
+				ModelId:      modelId,
+				ModelSlug:    modelSlug,
+				Sort:         api.SortBy(sortBy),
+				Timeframe:    sortByViewsTimeframe,
+				DurationGte:  durationGte,
+				DurationLt:   durationLt,
+				UserAgent:    userAgent,
+				GroupId:      groupId,
+				Amount:       amount,
+				Ip:           net.ParseIP(ip),
+			}).Cached(db.CacheFresh(cacheKey+":data", nocache))
+			// getting channel information from cache or from api
 			channelInfoCacheTtl := time.Hour*24 + time.Duration(rand.Intn(3600*6))*time.Second
-			channelInfoCached, err := db.GetCachedTimeout(channelInfoCacheKey, channelInfoCacheTtl, time.Hour*4, func() ([]byte, error) {
-				_, rawResponse, err := api.ChannelInfo(config, langId, channelId, channelSlug)
-				return rawResponse, err
-			}, nocache)
+			channelInfoCached, err := db.GetCachedTimeout(channelInfoCacheKey, channelInfoCacheTtl, time.Hour*4, channelInfoCall.Raw, nocache)
 			if err != nil {
 				log.Println(err)
 				return ctx, err
@@ -116,26 +135,7 @@ var Channel = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			}
 			var results = new(types.ContentResults)
 			var response json.RawMessage
-			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), func() ([]byte, error) {
-				return api.ContentRaw(config, api.ContentParams{
-					Lang:         langId,
-					Page:         page,
-					CategoryId:   categoryId,
-					CategorySlug: categorySlug,
-					ChannelId:    channelId,
-					ChannelSlug:  channelSlug,
-					ModelId:      modelId,
-					ModelSlug:    modelSlug,
-					Sort:         api.SortBy(sortBy),
-					Timeframe:    sortByViewsTimeframe,
-					DurationGte:  durationGte,
-					DurationLt:   durationLt,
-					UserAgent:    userAgent,
-					GroupId:      groupId,
-					Amount:       amount,
-					Ip:           net.ParseIP(ip),
-				})
-			}, nocache)
+			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), contentCall.Raw, nocache)
 			if err != nil {
 				return ctx, err
 			}
@@ -9,7 +9,7 @@ import (
 	"sersh.com/totaltube/frontend/types"
 )
 
-func getChannelsListFunc(config *types.Config, langId string, defaultAmount int64, groupId int64) func(args ...any) *types.ChannelResults {
+func getChannelsListFunc(batch *api.Batch, langId string, defaultAmount int64, groupId int64) func(args ...any) *types.ChannelResults {
 	return func(args ...any) *types.ChannelResults {
 		parsingName := true
 		var amount = defaultAmount
@@ -35,11 +35,11 @@ func getChannelsListFunc(config *types.Config, langId string, defaultAmount int6
 				amount, _ = strconv.ParseInt(val, 10, 64)
 			}
 		}
-		results, _, err := api.ChannelsList(config, langId, page, sortBy, amount, groupId)
+		results, err := batch.ChannelsList(langId, page, sortBy, amount, groupId).Remember().Result()
 		if err != nil {
 			log.Println("can't get channels list:", err)
 			return nil
 		}
-		return results
+		return results.(*types.ChannelResults)
 	}
 }
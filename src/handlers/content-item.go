@@ -204,9 +204,10 @@ var ContentItem = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 			var results = new(types.ContentItemResult)
 			var err error
 			var response json.RawMessage
-			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), func() ([]byte, error) {
-				return api.ContentItemRaw(config, langId, slug, id, orfl, int64(relatedAmount), groupId, relatedParams)
-			}, nocache)
+			// content item request is sent in one round trip with the requests of the template functions
+			contentItemCall := pageBatch(customContext).ContentItem(langId, slug, id, orfl, int64(relatedAmount), groupId, relatedParams).
+				Cached(db.CacheFresh(cacheKey+":data", nocache))
+			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), contentItemCall.Raw, nocache)
 			if err != nil {
 				if strings.Contains(err.Error(), "not found") {
 					return ctx, errors.New("content item not found")
@@ -284,7 +285,7 @@ var ContentItem = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 	render.HTML(w, r, string(parsed))
 })
 
-func getContentItemFunc(config *types.Config, langId string, groupId int64, nocache bool) func(args ...any) *types.ContentItemResult {
+func getContentItemFunc(batch *api.Batch, config *types.Config, langId string, groupId int64, nocache bool) func(args ...any) *types.ContentItemResult {
 	relatedTitleTranslated := config.Related.TitleTranslated
 	if relatedTitleTranslated == nil {
 		relatedTitleTranslated = internal.Config.Related.TitleTranslated
@@ -400,7 +401,7 @@ func getContentItemFunc(config *types.Config, langId string, groupId int64, noca
 			fmt.Sprintf("%s:%s:%d:%s:%v:%d:%d:%d", config.Hostname, langId, id, slug, orfl, relatedAmount, groupId, relatedRandomizeLast),
 		)
 		results, err := db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTime), time.Duration(cacheTime), func() ([]byte, error) {
-			return api.ContentItemRaw(config, langId, slug, id, orfl, relatedAmount, groupId, relatedParams)
+			return batch.ContentItem(langId, slug, id, orfl, relatedAmount, groupId, relatedParams).RememberCached(cacheKey + ":data").Raw()
 		}, nocache)
 		if err != nil {
 			log.Println("can't get content item:", err, config.Hostname)
@@ -10,7 +10,7 @@ import (
 	"sersh.com/totaltube/frontend/types"
 )
 
-func getContentFunc(config *types.Config, langId string, userAgent string, ip string, groupId int64) func(args ...any) *types.ContentResults {
+func getContentFunc(batch *api.Batch, langId string, userAgent string, ip string, groupId int64) func(args ...any) *types.ContentResults {
 	return func(args ...any) *types.ContentResults {
 		parsingName := true
 		params := api.ContentParams{
@@ -67,11 +67,11 @@ func getContentFunc(config *types.Config, langId string, userAgent string, ip st
 				params.GroupId, _ = strconv.ParseInt(val, 10, 32)
 			}
 		}
-		if results, _, err := api.Content(config, params); err != nil {
+		if results, err := batch.Content(params).Remember().Result(); err != nil {
 			log.Println("error getting content: ", err)
 			return nil
 		} else {
-			return results
+			return results.(*types.ContentResults)
 		}
 	}
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/middlewares"
+	"sersh.com/totaltube/frontend/site"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// errorTemplate returns the template for the error status: <status>.twig, then 4xx.twig or 5xx.twig.
+func errorTemplate(status int, path string) (string, bool) {
+	name := strconv.Itoa(status)
+	if site.TemplateExists(name, path) {
+		return name, true
+	}
+	name = name[:1] + "xx"
+	return name, site.TemplateExists(name, path)
+}
+
+// OutputError outputs error page of the site with the status. Message is available in the template as error variable.
+// Without the site template, or for requests without site, plain status text is sent.
+func OutputError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	outputError(w, r, status, message, nil)
+}
+
+func outputError(w http.ResponseWriter, r *http.Request, status int, message string, extraContext pongo2.Context) {
+	path, _ := r.Context().Value(types.ContextKeyPath).(string)
+	config, _ := r.Context().Value(types.ContextKeyConfig).(*types.Config)
+	if config == nil || path == "" {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if status >= 500 {
+		if page, ok := site.DevErrorPage(config); ok {
+			render.Status(r, status)
+			render.HTML(w, r, string(page))
+			return
+		}
+	}
+	name, ok := errorTemplate(status, path)
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	nocache, _ := strconv.ParseBool(r.URL.Query().Get(config.Params.Nocache))
+	hostName, _ := r.Context().Value(types.ContextKeyHostName).(string)
+	langId, _ := r.Context().Value(types.ContextKeyLang).(string)
+	customContext := generateCustomContext(w, r, name)
+	// error pages, like 429 and 403 of bad bots, can be requested a lot, so api calls of the template are not
+	// prefetched and remembered for them. The rendered page is cached by host, language, status and message.
+	delete(customContext, "api_batch")
+	customContext["error"] = message
+	customContext["status"] = status
+	for k, v := range extraContext {
+		customContext[k] = v
+	}
+	cacheKey := fmt.Sprintf("%d:%s:%s:%s", status, hostName, langId, helpers.Md5Hash(message+fmt.Sprint(extraContext)))
+	cacheTtl := time.Minute * 5
+	parsed, err := site.ParseTemplate(name, path, config, customContext, nocache, cacheKey, cacheTtl,
+		func() (pongo2.Context, error) {
+			ctx := pongo2.Context{}
+			return ctx, nil
+		}, w, r)
+	if err != nil {
+		log.Println(err, hostName, langId)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	render.Status(r, status)
+	render.HTML(w, r, string(parsed))
+}
+
+// Recoverer recovers from panic in the site handlers, logs it with the host, route and stack
+// and outputs 500 page of the site.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w = middlewares.WithHeadersCheck(w)
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+			hostName, _ := r.Context().Value(types.ContextKeyHostName).(string)
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			log.Printf("panic serving %s%s (route %s): %v\n%s", hostName, r.URL.RequestURI(), route, rvr, debug.Stack())
+			if middlewares.HeadersSent(w) {
+				return
+			}
+			message := http.StatusText(http.StatusInternalServerError)
+			if internal.Config.General.Development {
+				message = fmt.Sprint(rvr)
+			}
+			OutputError(w, r, http.StatusInternalServerError, message)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
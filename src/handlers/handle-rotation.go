@@ -13,6 +13,7 @@ import (
 
 	"github.com/go-chi/render"
 	"github.com/tidwall/gjson"
+	"sersh.com/totaltube/frontend/helpers"
 	"sersh.com/totaltube/frontend/internal"
 	"sersh.com/totaltube/frontend/types"
 )
@@ -59,15 +60,7 @@ func handleRotation(rotationParams rotationParams, useTrade bool, config *types.
 			req.Header.Set("User-Agent", r.Header.Get("User-Agent"))
 			req.Header.Set(internal.Config.General.RealIpHeader, r.Header.Get(internal.Config.General.RealIpHeader))
 			req.Header.Set("Referer", r.Header.Get("Referer"))
-			var client = http.Client{
-				Transport: &http.Transport{
-					MaxIdleConns:        100,  // Максимальное количество неактивных соединений, которое можно сохранять в пуле
-					MaxIdleConnsPerHost: 100,  // Максимальное количество неактивных соединений с одним хостом
-					MaxConnsPerHost:     2000, // Максимальное количество соединений
-					DisableKeepAlives:   true,
-				},
-				Timeout: time.Second * 10,
-			}
+			var client = helpers.HttpClient(helpers.DestinationTimeout(req.URL.Hostname(), time.Second*10))
 			resp, err := client.Do(req)
 			if err != nil {
 				log.Println(err, params, hostName)
@@ -128,17 +121,9 @@ func handleRotation(rotationParams rotationParams, useTrade bool, config *types.
 			req.Header.Del("Accept-Encoding")
 			req.Header.Del("Connection")
 
-			var client = http.Client{
-				Transport: &http.Transport{
-					MaxIdleConns:        100,  // Максимальное количество неактивных соединений, которое можно сохранять в пуле
-					MaxIdleConnsPerHost: 100,  // Максимальное количество неактивных соединений с одним хостом
-					MaxConnsPerHost:     2000, // Максимальное количество соединений
-					DisableKeepAlives:   true,
-				},
-				CheckRedirect: func(req *http.Request, via []*http.Request) error {
-					return http.ErrUseLastResponse
-				},
-				Timeout: time.Second * 2,
+			var client = helpers.HttpClient(helpers.DestinationTimeout(req.URL.Hostname(), time.Second*2))
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
 			}
 			resp, err := client.Do(req)
 			if err != nil {
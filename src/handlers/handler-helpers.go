@@ -10,14 +10,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/mileusna/useragent"
 	"github.com/samber/lo"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/render"
 
 	"sersh.com/totaltube/frontend/api"
 	"sersh.com/totaltube/frontend/helpers"
@@ -29,6 +27,7 @@ import (
 func generateCustomContext(_ http.ResponseWriter, r *http.Request, templateName string) pongo2.Context {
 	config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
 	hostName := r.Context().Value(types.ContextKeyHostName).(string)
+	subdomain, _ := r.Context().Value(types.ContextKeySubdomain).(string)
 	langId := r.Context().Value(types.ContextKeyLang).(string)
 	refreshTranslations := r.URL.Query().Get(config.Params.Nocache) == "3"
 	page, _ := strconv.ParseInt(helpers.FirstNotEmpty(chi.URLParam(r, "page"), r.URL.Query().Get(config.Params.Page), "1"), 10, 16)
@@ -248,16 +247,22 @@ func generateCustomContext(_ http.ResponseWriter, r *http.Request, templateName
 	ip := r.Context().Value(types.ContextKeyIp).(string)
 	var countryGroup = internal.DetectCountryGroup(net.ParseIP(ip))
 	groupId := countryGroup.Id
+	// api requests of the template functions are sent in one batch, see site.prefetchBatch
+	batch := api.NewBatch(config)
 	customContext := pongo2.Context{
+		"api_batch":           batch,
 		"page_template":       templateName,
 		"lang":                internal.GetLanguage(langId),
 		"ip":                  ip,
 		"uri":                 uri,
 		"user_agent":          userAgent,
+		"device":              site.DetectDevice(config, userAgent),
 		"nocache":             nocache,
+		"preview":             r.Context().Value(types.ContextKeyPreview) == true,
 		"languages":           internal.GetLanguages(config),
 		"page":                page,
 		"host":                hostName,
+		"subdomain":           subdomain,
 		"params":              params,
 		"query":               query,
 		"querystring":         queryString,
@@ -266,6 +271,7 @@ func generateCustomContext(_ http.ResponseWriter, r *http.Request, templateName
 		"canonical_query":     canonicalQuery,
 		"config":              config,
 		"global_config":       internal.Config,
+		"offline":             internal.IsOffline(),
 		"route":               route,
 		"country_group":       countryGroup,
 		"group_id":            countryGroup.Id,
@@ -276,18 +282,18 @@ func generateCustomContext(_ http.ResponseWriter, r *http.Request, templateName
 			}
 			return useragent.Parse(r.UserAgent())
 		},
-		"get_content":         getContentFunc(config, langId, userAgent, ip, groupId),
-		"get_top_content":     getTopContentFunc(config, langId, groupId),
-		"get_top_categories":  getTopCategoriesFunc(config, langId, groupId),
-		"get_content_item":    getContentItemFunc(config, langId, groupId, nocache),
-		"get_models_list":     getModelsListFunc(config, langId, int64(config.General.ModelsPerPage), groupId),
-		"get_categories_list": getCategoriesListFunc(config, langId, 100, groupId),
-		"get_channels_list":   getChannelsListFunc(config, langId, 100, groupId),
-		"get_category_top":    getCategoryTopFunc(config, langId, groupId),
-		"get_category":        getCategoryFunc(config, langId),
-		"get_model":           getModelFunc(config, langId, groupId),
-		"get_top_searches":    getTopSearchesFunc(config, langId),
-		"get_random_searches": getRandomSearchesFunc(config, langId),
+		"get_content":         getContentFunc(batch, langId, userAgent, ip, groupId),
+		"get_top_content":     getTopContentFunc(batch, config, langId, groupId),
+		"get_top_categories":  getTopCategoriesFunc(batch, config, langId, groupId),
+		"get_content_item":    getContentItemFunc(batch, config, langId, groupId, nocache),
+		"get_models_list":     getModelsListFunc(batch, langId, int64(config.General.ModelsPerPage), groupId),
+		"get_categories_list": getCategoriesListFunc(batch, langId, 100, groupId),
+		"get_channels_list":   getChannelsListFunc(batch, langId, 100, groupId),
+		"get_category_top":    getCategoryTopFunc(batch, config, langId, groupId),
+		"get_category":        getCategoryFunc(batch, langId),
+		"get_model":           getModelFunc(batch, langId, groupId),
+		"get_top_searches":    getTopSearchesFunc(batch, langId),
+		"get_random_searches": getRandomSearchesFunc(batch, langId),
 		"xor_id": func(id *pongo2.Value) int64 {
 			idInt := int64(id.Integer())
 			if idInt > 0 && config.Routes.IdXorKey > 0 {
@@ -343,51 +349,23 @@ func generateCustomContext(_ http.ResponseWriter, r *http.Request, templateName
 	return customContext
 }
 
+// pageBatch returns the api batch of the page render created by generateCustomContext.
+func pageBatch(customContext pongo2.Context) *api.Batch {
+	return customContext["api_batch"].(*api.Batch)
+}
+
+// TemplateContext returns the context templates are rendered with for the request.
+func TemplateContext(w http.ResponseWriter, r *http.Request, templateName string) pongo2.Context {
+	return generateCustomContext(w, r, templateName)
+}
+
 func Output404(w http.ResponseWriter, r *http.Request, errMessage string) {
-	path := r.Context().Value(types.ContextKeyPath).(string)
-	config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
-	nocache, _ := strconv.ParseBool(r.URL.Query().Get(config.Params.Nocache))
-	hostName := r.Context().Value(types.ContextKeyHostName).(string)
-	langId := r.Context().Value(types.ContextKeyLang).(string)
-	customContext := generateCustomContext(w, r, "404")
-	customContext["error"] = errMessage
-	cacheKey := fmt.Sprintf("404:%s:%s:%s", hostName, langId, helpers.Md5Hash(errMessage))
-	cacheTtl := time.Minute * 5
-	parsed, err := site.ParseTemplate("404", path, config, customContext, nocache, cacheKey, cacheTtl,
-		func() (pongo2.Context, error) {
-			ctx := pongo2.Context{}
-			return ctx, nil
-		}, w, r)
-	if err != nil {
-		panic(err)
-	}
-	render.Status(r, 404)
-	render.HTML(w, r, string(parsed))
+	OutputError(w, r, http.StatusNotFound, errMessage)
 }
 
 func Output500(w http.ResponseWriter, r *http.Request, err error) {
-	path := r.Context().Value(types.ContextKeyPath).(string)
-	config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
-	nocache, _ := strconv.ParseBool(r.URL.Query().Get(config.Params.Nocache))
-	hostName := r.Context().Value(types.ContextKeyHostName).(string)
-	langId := r.Context().Value(types.ContextKeyLang).(string)
-	customContext := generateCustomContext(w, r, "404")
-	customContext["error"] = err.Error()
+	hostName, _ := r.Context().Value(types.ContextKeyHostName).(string)
+	langId, _ := r.Context().Value(types.ContextKeyLang).(string)
 	log.Println(err, hostName, langId)
-	cacheKey := fmt.Sprintf("500:%s:%s:%s", hostName, langId, helpers.Md5Hash(err.Error()))
-	cacheTtl := time.Minute * 5
-	var parsed []byte
-	parsed, err = site.ParseTemplate("500", path, config, customContext, nocache, cacheKey, cacheTtl,
-		func() (pongo2.Context, error) {
-			ctx := pongo2.Context{}
-			return ctx, nil
-		}, w, r)
-	if err != nil {
-		log.Println(err, hostName, langId)
-		render.Status(r, 500)
-		render.PlainText(w, r, "Internal server error")
-		return
-	}
-	render.Status(r, 500)
-	render.HTML(w, r, string(parsed))
+	OutputError(w, r, http.StatusInternalServerError, err.Error())
 }
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+)
+
+// adminSitePath returns path of the site from host url param of admin api, empty string if there is no such site.
+// Only directories right in sites_path are sites.
+func adminSitePath(r *http.Request) string {
+	host := internal.NormalizeHost(chi.URLParam(r, "host"))
+	if host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) {
+		return ""
+	}
+	sitesPath := filepath.Clean(internal.Config.Frontend.SitesPath)
+	sitePath := filepath.Join(sitesPath, host)
+	if filepath.Dir(sitePath) != sitesPath {
+		return ""
+	}
+	if info, err := os.Stat(sitePath); err != nil || !info.IsDir() {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(sitePath, "config.toml")); err != nil {
+		return ""
+	}
+	return sitePath
+}
+
+func historyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// History lists saved versions of the site file, or of all site files without file param.
+var History = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	versions, err := site.History(sitePath, r.URL.Query().Get("file"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	if versions == nil {
+		versions = []db.FileVersion{}
+	}
+	render.JSON(w, r, versions)
+})
+
+// HistoryDiff outputs unified diff of two versions of the site file.
+var HistoryDiff = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	diff, err := site.HistoryDiff(sitePath, q.Get("file"), q.Get("from"), q.Get("to"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(diff))
+})
+
+// HistoryRollback restores the site file, or the whole site without file param, to the version of to param.
+var HistoryRollback = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	restored, err := site.HistoryRollback(sitePath, q.Get("file"), q.Get("to"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	if restored == nil {
+		restored = []string{}
+	}
+	render.JSON(w, r, map[string]any{"restored": restored})
+})
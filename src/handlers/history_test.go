@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"sersh.com/totaltube/frontend/internal"
+)
+
+func TestAdminSitePath(t *testing.T) {
+	root := t.TempDir()
+	sitesPath := filepath.Join(root, "sites")
+	for _, dir := range []string{root, filepath.Join(sitesPath, "example.com"), filepath.Join(sitesPath, "nocfg.com")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(root, "config.toml"), filepath.Join(sitesPath, "config.toml"),
+		filepath.Join(sitesPath, "example.com", "config.toml")} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := internal.Config
+	internal.Config = &internal.ConfigT{}
+	internal.Config.Frontend.SitesPath = sitesPath
+	t.Cleanup(func() { internal.Config = config })
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "example.com", want: filepath.Join(sitesPath, "example.com")},
+		{host: "WWW.Example.com:8080", want: filepath.Join(sitesPath, "example.com")},
+		{host: "nocfg.com"},
+		{host: "other.com"},
+		{host: "."},
+		{host: ".."},
+		{host: "../sites"},
+		{host: "..:8080"},
+		{host: ""},
+	}
+	for _, tt := range tests {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("host", tt.host)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		if got := adminSitePath(r); got != tt.want {
+			t.Errorf("adminSitePath(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/samber/lo"
-	"sersh.com/totaltube/frontend/middlewares"
 	"sersh.com/totaltube/frontend/types"
 
 	"github.com/go-chi/chi/v5"
@@ -196,7 +195,7 @@ func LangHandlers(hr *chi.Mux, route string, siteConfig *types.Config, handler h
 					return
 				}
 			}
-			middlewares.BadBotMiddleware(handler).ServeHTTP(w, r.WithContext(ctx))
+			handler.ServeHTTP(w, r.WithContext(ctx))
 		}))
 	}
 	if strings.Contains(route, "{lang}") {
@@ -292,7 +291,7 @@ func LangHandlers(hr *chi.Mux, route string, siteConfig *types.Config, handler h
 			if siteConfig.General.NoRedirectDefaultLanguage && lang.Id == siteConfig.General.DefaultLanguage {
 				ctx := context.WithValue(r.Context(), types.ContextKeyLang, lang.Id)
 				ctx = context.WithValue(ctx, types.ContextKeyIsXDefault, true)
-				middlewares.BadBotMiddleware(handler).ServeHTTP(w, r.WithContext(ctx))
+				handler.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 			redirectUri = strings.ReplaceAll(redirectUri, "{route}", route)
@@ -348,7 +347,7 @@ func LangHandlers(hr *chi.Mux, route string, siteConfig *types.Config, handler h
 			if siteConfig.General.NoRedirectDefaultLanguage && lang.Id == siteConfig.General.DefaultLanguage || redirectUri == uri {
 				ctx := context.WithValue(r.Context(), types.ContextKeyLang, lang.Id)
 				ctx = context.WithValue(ctx, types.ContextKeyIsXDefault, true)
-				middlewares.BadBotMiddleware(handler).ServeHTTP(w, r.WithContext(ctx))
+				handler.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 			if r.URL.RawQuery != "" {
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+
+	"sersh.com/totaltube/frontend/api"
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// Retry-After of closed write routes in offline mode, api is checked every second until it's back.
+const offlineRetryAfter = time.Minute
+
+var maintenanceOverrides sync.Map // host -> *types.ConfigMaintenance set with admin api, nil if it's not set
+
+// siteMaintenance returns maintenance mode of the site. Mode set with admin api overrides [maintenance] of the config.
+func siteMaintenance(config *types.Config) (types.ConfigMaintenance, bool) {
+	v, ok := maintenanceOverrides.Load(config.Hostname)
+	if !ok {
+		m, err := db.LoadMaintenance(config.Hostname)
+		if err != nil {
+			log.Println("can't load maintenance mode of", config.Hostname, err)
+		}
+		v, _ = maintenanceOverrides.LoadOrStore(config.Hostname, m)
+	}
+	if m := v.(*types.ConfigMaintenance); m != nil {
+		return *m, true
+	}
+	return config.Maintenance, false
+}
+
+// ForgetMaintenance drops maintenance mode of the removed site from memory. Mode saved with admin api is loaded
+// again if the site is added back.
+func ForgetMaintenance(host string) {
+	maintenanceOverrides.Delete(host)
+}
+
+// maintenanceAllowed checks if the site is served as usual to the request by allowed ips and cookie.
+func maintenanceAllowed(r *http.Request, m types.ConfigMaintenance) bool {
+	if m.AllowCookie != "" {
+		name, value, withValue := strings.Cut(m.AllowCookie, "=")
+		if c, err := r.Cookie(name); err == nil && (!withValue || c.Value == value) {
+			return true
+		}
+	}
+	ip := net.ParseIP(r.Context().Value(types.ContextKeyIp).(string))
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range m.AllowIps {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenancePathAllowed checks if the path is served as usual during maintenance: robots.txt and allow_paths.
+func maintenancePathAllowed(r *http.Request, m types.ConfigMaintenance) bool {
+	if r.URL.Path == "/robots.txt" {
+		return true
+	}
+	for _, prefix := range m.AllowPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func maintenanceReadOnlyMethod(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// MaintenanceMiddleware serves 503 page while the site is in maintenance. Read-only sites serve GET and HEAD requests.
+// Allowed ips and cookie get the site as usual, static files too are served only from allowed paths.
+func MaintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
+		m, _ := siteMaintenance(config)
+		if !m.Enabled || m.ReadOnly && maintenanceReadOnlyMethod(r) || maintenancePathAllowed(r, m) || maintenanceAllowed(r, m) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		OutputMaintenance(w, r, m)
+	})
+}
+
+// MaintenanceWrites closes routes which change data, like rating, while the site is read-only or served offline.
+func MaintenanceWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if internal.IsOffline() {
+			// minion is not available, writes fail anyway
+			OutputMaintenance(w, r, types.ConfigMaintenance{Enabled: true, ReadOnly: true, RetryAfter: types.Duration(offlineRetryAfter)})
+			return
+		}
+		config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
+		if m, _ := siteMaintenance(config); m.Enabled && m.ReadOnly && !maintenanceAllowed(r, m) {
+			OutputMaintenance(w, r, m)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OutputMaintenance outputs 503.twig template with Retry-After header. Search engine bots get just 503 status,
+// so they come back later and keep the site rankings.
+func OutputMaintenance(w http.ResponseWriter, r *http.Request, m types.ConfigMaintenance) {
+	ip := r.Context().Value(types.ContextKeyIp).(string)
+	retryAfter := time.Duration(m.RetryAfter)
+	if retryAfter <= 0 {
+		retryAfter = time.Hour
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(retryAfter/time.Second), 10))
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+	if isSEBot, _ := db.CheckIfSeBot(ip); isSEBot {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	outputError(w, r, http.StatusServiceUnavailable, "maintenance", pongo2.Context{
+		"retry_after": int64(retryAfter / time.Second),
+		"read_only":   m.ReadOnly,
+	})
+}
+
+// MaintenanceStatus outputs maintenance mode of the site and whether it's set with admin api.
+var MaintenanceStatus = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	config := internal.GetConfig(filepath.Join(sitePath, "config.toml"), api.UpdateConfigRetry)
+	m, overridden := siteMaintenance(config)
+	render.JSON(w, r, M{"maintenance": m, "admin": overridden})
+})
+
+// MaintenanceSet sets maintenance mode of the site from enabled, read_only, retry_after, allow_ips (comma separated)
+// and allow_cookie params. It overrides [maintenance] of the site config until MaintenanceReset.
+var MaintenanceSet = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	m, err := parseMaintenance(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	host := filepath.Base(sitePath)
+	if err = db.SaveMaintenance(host, &m); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	maintenanceOverrides.Store(host, &m)
+	log.Println("maintenance mode of", host, "set:", m.Enabled)
+	render.JSON(w, r, M{"maintenance": m, "admin": true})
+})
+
+// parseMaintenance reads maintenance mode from MaintenanceSet params.
+func parseMaintenance(q url.Values) (m types.ConfigMaintenance, err error) {
+	if m.Enabled, err = strconv.ParseBool(q.Get("enabled")); err != nil {
+		return m, errors.Wrap(err, "bad enabled param")
+	}
+	m.ReadOnly, _ = strconv.ParseBool(q.Get("read_only"))
+	if v := q.Get("retry_after"); v != "" {
+		// human duration, like "30m" or "1 hour"; unknown values are parsed to zero
+		if m.RetryAfter = types.Duration(types.ParseHumanDuration(v)); m.RetryAfter <= 0 {
+			return m, errors.New("bad retry_after param: " + v)
+		}
+	}
+	for _, ip := range strings.Split(q.Get("allow_ips"), ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			m.AllowIps = append(m.AllowIps, ip)
+		}
+	}
+	m.AllowCookie = q.Get("allow_cookie")
+	for _, p := range strings.Split(q.Get("allow_paths"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			m.AllowPaths = append(m.AllowPaths, p)
+		}
+	}
+	return
+}
+
+// MaintenanceReset removes maintenance mode set with admin api, so [maintenance] of the site config is used again.
+var MaintenanceReset = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	sitePath := adminSitePath(r)
+	if sitePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	host := filepath.Base(sitePath)
+	if err := db.SaveMaintenance(host, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	maintenanceOverrides.Store(host, (*types.ConfigMaintenance)(nil))
+	log.Println("maintenance mode of", host, "reset")
+	config := internal.GetConfig(filepath.Join(sitePath, "config.toml"), api.UpdateConfigRetry)
+	m, _ := siteMaintenance(config)
+	render.JSON(w, r, M{"maintenance": m, "admin": false})
+})
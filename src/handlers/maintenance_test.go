@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+func TestMaintenanceAllowed(t *testing.T) {
+	m := types.ConfigMaintenance{
+		Enabled:     true,
+		AllowIps:    []string{"203.0.113.7", "10.0.0.0/8", "2001:db8::/32", "bad ip"},
+		AllowCookie: "tt_staff=1",
+	}
+	tests := []struct {
+		name   string
+		ip     string
+		cookie string
+		m      *types.ConfigMaintenance
+		want   bool
+	}{
+		{name: "allowed ip", ip: "203.0.113.7", want: true},
+		{name: "ip in network", ip: "10.20.30.40", want: true},
+		{name: "ipv6 in network", ip: "2001:db8::1", want: true},
+		{name: "other ip", ip: "203.0.113.8"},
+		{name: "bad request ip", ip: "unknown"},
+		{name: "cookie with value", ip: "1.1.1.1", cookie: "tt_staff=1", want: true},
+		{name: "cookie with other value", ip: "1.1.1.1", cookie: "tt_staff=0"},
+		{name: "other cookie", ip: "1.1.1.1", cookie: "staff=1"},
+		{name: "any cookie value", ip: "1.1.1.1", cookie: "tt_staff=yes", m: &types.ConfigMaintenance{AllowCookie: "tt_staff"},
+			want: true},
+		{name: "nothing allowed", ip: "203.0.113.7", cookie: "tt_staff=1", m: &types.ConfigMaintenance{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyIp, tt.ip))
+			if name, value, ok := strings.Cut(tt.cookie, "="); ok {
+				r.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			mode := m
+			if tt.m != nil {
+				mode = *tt.m
+			}
+			if got := maintenanceAllowed(r, mode); got != tt.want {
+				t.Fatalf("allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaintenancePathAllowed(t *testing.T) {
+	m := types.ConfigMaintenance{Enabled: true, AllowPaths: []string{"/css/", "/img/503.png"}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/robots.txt", want: true},
+		{path: "/css/main.css", want: true},
+		{path: "/img/503.png", want: true},
+		{path: "/img/logo.png"},
+		{path: "/css"},
+		{path: "/"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if got := maintenancePathAllowed(r, m); got != tt.want {
+			t.Errorf("%s: allowed = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseMaintenance(t *testing.T) {
+	tests := []struct {
+		query string
+		want  types.ConfigMaintenance
+		err   string
+	}{
+		{query: "enabled=1", want: types.ConfigMaintenance{Enabled: true}},
+		{query: "enabled=0&read_only=1", want: types.ConfigMaintenance{ReadOnly: true}},
+		{query: "enabled=true&retry_after=30m",
+			want: types.ConfigMaintenance{Enabled: true, RetryAfter: types.Duration(30 * time.Minute)}},
+		{query: "enabled=1&retry_after=2+hours",
+			want: types.ConfigMaintenance{Enabled: true, RetryAfter: types.Duration(2 * time.Hour)}},
+		{query: "enabled=1&allow_ips=203.0.113.7,+10.0.0.0/8,,&allow_cookie=tt_staff=1",
+			want: types.ConfigMaintenance{Enabled: true, AllowIps: []string{"203.0.113.7", "10.0.0.0/8"}, AllowCookie: "tt_staff=1"}},
+		{query: "enabled=1&allow_paths=/css/,+/robots2.txt",
+			want: types.ConfigMaintenance{Enabled: true, AllowPaths: []string{"/css/", "/robots2.txt"}}},
+		{query: "", err: "bad enabled param"},
+		{query: "enabled=maybe", err: "bad enabled param"},
+		{query: "enabled=1&retry_after=soon", err: "bad retry_after param: soon"},
+		{query: "enabled=1&retry_after=120", err: "bad retry_after param: 120"},
+		{query: "enabled=1&retry_after=0s", err: "bad retry_after param: 0s"},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		m, err := parseMaintenance(q)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if m.Enabled != tt.want.Enabled || m.ReadOnly != tt.want.ReadOnly || m.RetryAfter != tt.want.RetryAfter ||
+			strings.Join(m.AllowIps, ",") != strings.Join(tt.want.AllowIps, ",") || m.AllowCookie != tt.want.AllowCookie ||
+			strings.Join(m.AllowPaths, ",") != strings.Join(tt.want.AllowPaths, ",") {
+			t.Errorf("%s: %+v, want %+v", tt.query, m, tt.want)
+		}
+	}
+}
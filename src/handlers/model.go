@@ -99,13 +99,32 @@ var Model = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	parsed, err := site.ParseTemplate("model", path, config, customContext, nocache, cacheKey, time.Duration(cacheTtl),
 		func() (pongo2.Context, error) {
 			ctx := pongo2.Context{}
-			// getting category information from cache or from api
+			// all api requests needed for the page, which are not cached, are sent in one round trip
 			modelInfoCacheKey := fmt.Sprintf("in:minfo:%d:%s:%s", modelId, modelSlug, langId)
+			batch := pageBatch(customContext)
+			modelInfoCall := batch.ModelInfo(langId, modelId, modelSlug, groupId).
+				Cached(db.CacheFresh(modelInfoCacheKey, nocache))
+			contentCall := batch.Content(api.ContentParams{
+				Lang:         langId,
+				Page:         page,
+				CategoryId:   categoryId,
+				CategorySlug: categorySlug,
+				ChannelId:    channelId,
+				ChannelSlug:  channelSlug,
+				ModelId:      modelId,
+				ModelSlug:    modelSlug,
+				Sort:         api.SortBy(sortBy),
+				Timeframe:    sortByViewsTimeframe,
+				DurationGte:  durationFrom,
+				DurationLt:   durationTo,
+				UserAgent:    userAgent,
+				GroupId:      groupId,
+				Amount:       amount,
+				Ip:           net.ParseIP(ip),
+			}).Cached(db.CacheFresh(cacheKey+":data", nocache))
+			// getting model information from cache or from api
 			modelInfoCacheTtl := time.Hour*24 + time.Duration(rand.Intn(3600*6))*time.Second
-			modelInfoCached, err := db.GetCachedTimeout(modelInfoCacheKey, modelInfoCacheTtl, time.Hour*4, func() ([]byte, error) {
-				_, rawResponse, err := api.ModelInfo(config, langId, modelId, modelSlug, groupId)
-				return rawResponse, err
-			}, nocache)
+			modelInfoCached, err := db.GetCachedTimeout(modelInfoCacheKey, modelInfoCacheTtl, time.Hour*4, modelInfoCall.Raw, nocache)
 			if err != nil {
 				log.Println(err, hostName, ip)
 				return ctx, err
@@ -118,26 +137,7 @@ var Model = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			}
 			var results = new(types.ContentResults)
 			var response json.RawMessage
-			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), func() ([]byte, error) {
-				return api.ContentRaw(config, api.ContentParams{
-					Lang:         langId,
-					Page:         page,
-					CategoryId:   categoryId,
-					CategorySlug: categorySlug,
-					ChannelId:    channelId,
-					ChannelSlug:  channelSlug,
-					ModelId:      modelId,
-					ModelSlug:    modelSlug,
-					Sort:         api.SortBy(sortBy),
-					Timeframe:    sortByViewsTimeframe,
-					DurationGte:  durationFrom,
-					DurationLt:   durationTo,
-					UserAgent:    userAgent,
-					GroupId:      groupId,
-					Amount:       amount,
-					Ip:           net.ParseIP(ip),
-				})
-			}, nocache)
+			response, err = db.GetCachedTimeout(cacheKey+":data", time.Duration(cacheTtl), time.Duration(cacheTtl), contentCall.Raw, nocache)
 			if err != nil {
 				return ctx, err
 			}
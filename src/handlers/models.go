@@ -99,7 +99,7 @@ var Models = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	render.HTML(w, r, string(parsed))
 })
 
-func getModelFunc(config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.ModelResult {
+func getModelFunc(batch *api.Batch, langId string, groupId int64) func(args ...interface{}) *types.ModelResult {
 	return func(args ...interface{}) *types.ModelResult {
 		parsingName := true
 		var modelId int64
@@ -120,15 +120,15 @@ func getModelFunc(config *types.Config, langId string, groupId int64) func(args
 				modelSlug = val
 			}
 		}
-		results, _, err := api.ModelInfo(config, langId, modelId, modelSlug, groupId)
+		results, err := batch.ModelInfo(langId, modelId, modelSlug, groupId).Remember().Result()
 		if err != nil {
 			log.Println("can't get model info:", err)
 			return nil
 		}
-		return results
+		return results.(*types.ModelResult)
 	}
 }
-func getModelsListFunc(config *types.Config, langId string, defaultAmount int64, groupId int64) func(args ...interface{}) *types.ModelResults {
+func getModelsListFunc(batch *api.Batch, langId string, defaultAmount int64, groupId int64) func(args ...interface{}) *types.ModelResults {
 	return func(args ...interface{}) *types.ModelResults {
 		parsingName := true
 		var amount = defaultAmount
@@ -157,11 +157,11 @@ func getModelsListFunc(config *types.Config, langId string, defaultAmount int64,
 				searchQuery = val
 			}
 		}
-		results, _, err := api.ModelsList(config, langId, page, sortBy, amount, searchQuery, groupId)
+		results, err := batch.ModelsList(langId, page, sortBy, amount, searchQuery, groupId).Remember().Result()
 		if err != nil {
 			log.Println("can't get models list:", err)
 			return nil
 		}
-		return results
+		return results.(*types.ModelResults)
 	}
 }
@@ -186,7 +186,7 @@ var Search = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	render.HTML(w, r, string(parsed))
 })
 
-func getTopSearchesFunc(config *types.Config, langId string) func(args ...any) []types.TopSearch {
+func getTopSearchesFunc(batch *api.Batch, langId string) func(args ...any) []types.TopSearch {
 	return func(args ...any) []types.TopSearch {
 		currentName := ""
 		parsingName := true
@@ -202,16 +202,16 @@ func getTopSearchesFunc(config *types.Config, langId string) func(args ...any) [
 				}
 			}
 		}
-		results, _, err := api.TopSearches(config, langId, int64(amount))
+		results, err := batch.TopSearches(langId, amount).Remember().Result()
 		if err != nil {
 			log.Println(err)
 			return nil
 		}
-		return results
+		return results.([]types.TopSearch)
 	}
 }
 
-func getRandomSearchesFunc(config *types.Config, langId string) func(args ...any) []types.TopSearch {
+func getRandomSearchesFunc(batch *api.Batch, langId string) func(args ...any) []types.TopSearch {
 	return func(args ...any) []types.TopSearch {
 		currentName := ""
 		parsingName := true
@@ -231,11 +231,11 @@ func getRandomSearchesFunc(config *types.Config, langId string) func(args ...any
 				}
 			}
 		}
-		results, _, err := api.RandomSearches(config, langId, int64(amount), int64(minSearches))
+		results, err := batch.RandomSearches(langId, amount, minSearches).Remember().Result()
 		if err != nil {
 			log.Println(err)
 			return nil
 		}
-		return results
+		return results.([]types.TopSearch)
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -24,7 +25,7 @@ import (
 	"sersh.com/totaltube/frontend/types"
 )
 
-func getTopCategoriesFunc(config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.CategoryResults {
+func getTopCategoriesFunc(batch *api.Batch, config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.CategoryResults {
 	return func(args ...interface{}) *types.CategoryResults {
 		parsingName := true
 		var page int64 = 1
@@ -46,18 +47,23 @@ func getTopCategoriesFunc(config *types.Config, langId string, groupId int64) fu
 				groupId, _ = strconv.ParseInt(val, 10, 32)
 			}
 		}
-		results, err := api.TopCategories(config, langId, page, groupId)
+		result, err := batch.TopCategories(langId, page, groupId).Remember().Result()
 		if err != nil {
 			log.Println("can't get top categories:", err)
 			return nil
 		}
+		results := result.(*types.CategoryResults)
 		if page == 1 {
 			randomizeRatio := config.General.RandomizeRatio
 			if randomizeRatio < 0 {
 				randomizeRatio = internal.Config.General.RandomizeRatio
 			}
 			if randomizeRatio > 0 {
-				helpers.RandomizeItems(results.Items, randomizeRatio)
+				// result of the batch call is shared by all calls with the same params
+				randomized := *results
+				randomized.Items = slices.Clone(results.Items)
+				helpers.RandomizeItems(randomized.Items, randomizeRatio)
+				return &randomized
 			}
 		}
 		return results
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -107,7 +108,7 @@ var TopContent = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	render.HTML(w, r, string(parsed))
 })
 
-func getTopContentFunc(config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.ContentResults {
+func getTopContentFunc(batch *api.Batch, config *types.Config, langId string, groupId int64) func(args ...interface{}) *types.ContentResults {
 	return func(args ...interface{}) *types.ContentResults {
 		parsingName := true
 		var page int64 = 1
@@ -129,17 +130,22 @@ func getTopContentFunc(config *types.Config, langId string, groupId int64) func(
 				groupId, _ = strconv.ParseInt(val, 10, 32)
 			}
 		}
-		results, err := api.TopContent(config, langId, page, groupId, []string{})
+		result, err := batch.TopContent(langId, page, groupId, []string{}).Remember().Result()
 		if err != nil {
 			log.Println("can't get top content:", err)
 			return nil
 		}
+		results := result.(*types.ContentResults)
 		randomizeRatio := config.General.RandomizeRatio
 		if randomizeRatio < 0 {
 			randomizeRatio = internal.Config.General.RandomizeRatio
 		}
 		if randomizeRatio > 0 {
-			helpers.RandomizeItems(results.Items, randomizeRatio)
+			// result of the batch call is shared by all calls with the same params
+			randomized := *results
+			randomized.Items = slices.Clone(results.Items)
+			helpers.RandomizeItems(randomized.Items, randomizeRatio)
+			return &randomized
 		}
 		return results
 	}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"sersh.com/totaltube/frontend/helpers"
+)
+
+// TransportStats outputs connection pool statistics of the shared http transport per destination host.
+var TransportStats = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, helpers.TransportStats())
+})
@@ -3,7 +3,6 @@ package helpers
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -63,12 +62,17 @@ func newFetchRequest(u string, config *types.Config) *FetchRequest {
 		} else {
 			timeout = time.Duration(internal.Config.General.ApiTimeout)
 		}
-		u = apiUrl + "v1/" + u
+		u = apiUrl + internal.ApiVersion(config) + "/" + u
+		if parsedApi, err := url.Parse(u); err == nil {
+			timeout = DestinationTimeout(parsedApi.Hostname(), timeout)
+		}
 		headers["Authorization"] = apiSecret
 		headers["Accept"] = "application/json"
 		if config != nil {
 			headers["Totaltube-Site"] = config.Hostname
 		}
+	} else {
+		timeout = DestinationTimeout(parsed.Hostname(), timeout)
 	}
 	n := FetchRequest{
 		method:  "GET",
@@ -186,17 +190,7 @@ func (f *FetchRequest) Do() (response []byte, err error) {
 	started := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
 	defer cancel()
-	var client = http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        100,  // Максимальное количество неактивных соединений, которое можно сохранять в пуле
-			MaxIdleConnsPerHost: 100,  // Максимальное количество неактивных соединений с одним хостом
-			MaxConnsPerHost:     2000, // Максимальное количество соединений
-			DisableKeepAlives:   true,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
-			DialContext:         dnsDialer,
-		},
-		Timeout: f.timeout,
-	}
+	var client = HttpClient(f.timeout)
 	var body io.Reader
 	if f.data != nil {
 		switch d := f.data.(type) {
@@ -248,7 +242,6 @@ func (f *FetchRequest) Do() (response []byte, err error) {
 		}
 		request.Header.Set(name, val)
 	}
-	request.Close = true
 	var resp *http.Response
 	resp, err = client.Do(request)
 	elapsed := time.Since(started)
@@ -259,6 +252,7 @@ func (f *FetchRequest) Do() (response []byte, err error) {
 		log.Println(err, request.Host)
 		return
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
 		err = errors.New(fmt.Sprintf("wrong status code: %d", resp.StatusCode))
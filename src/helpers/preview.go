@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"sersh.com/totaltube/frontend/internal"
+)
+
+func previewSignature(host, expire string) string {
+	mac := hmac.New(sha256.New, []byte(internal.Config.Frontend.SecretKey))
+	mac.Write([]byte("preview:" + host + ":" + expire))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PreviewToken returns the token for previewing draft templates of the host, valid until the time.
+// The token is signed with secret_key of the global config.
+func PreviewToken(host string, until time.Time) string {
+	expire := strconv.FormatInt(until.Unix(), 10)
+	return expire + "." + previewSignature(host, expire)
+}
+
+// ValidPreviewToken checks the signature and expiration time of the preview token.
+func ValidPreviewToken(host, token string) bool {
+	if internal.Config.Frontend.SecretKey == "" {
+		return false
+	}
+	expire, signature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	until, err := strconv.ParseInt(expire, 10, 64)
+	if err != nil || time.Now().Unix() > until {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(previewSignature(host, expire)))
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sersh.com/totaltube/frontend/internal"
+)
+
+// HostPoolStats is the connection pool statistics for one destination host.
+type HostPoolStats struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	NewConns     int64   `json:"new_conns"`
+	ReusedConns  int64   `json:"reused_conns"`
+	OpenConns    int64   `json:"open_conns"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+type hostPoolCounters struct {
+	requests    atomic.Int64
+	errors      atomic.Int64
+	newConns    atomic.Int64
+	reusedConns atomic.Int64
+	openConns   atomic.Int64
+	latency     atomic.Int64 // total nanoseconds
+	badCert     atomic.Bool  // invalid certificate of the host is logged
+}
+
+var poolCounters sync.Map // host -> *hostPoolCounters
+
+func countersFor(host string) *hostPoolCounters {
+	if c, ok := poolCounters.Load(host); ok {
+		return c.(*hostPoolCounters)
+	}
+	c, _ := poolCounters.LoadOrStore(host, &hostPoolCounters{})
+	return c.(*hostPoolCounters)
+}
+
+// countedConn decrements open connections counter of the host on close.
+type countedConn struct {
+	net.Conn
+	counters *hostPoolCounters
+	closed   atomic.Bool
+}
+
+func (c *countedConn) Close() error {
+	if !c.closed.Swap(true) {
+		c.counters.openConns.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+func countingDialer(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := dnsDialer(ctx, network, address)
+	if err != nil || conn == nil {
+		return conn, err
+	}
+	host, _, _ := net.SplitHostPort(address)
+	counters := countersFor(host)
+	counters.openConns.Add(1)
+	return &countedConn{Conn: conn, counters: counters}, nil
+}
+
+// statsTransport collects pool statistics for every request going through the shared transport.
+type statsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	counters := countersFor(req.URL.Hostname())
+	counters.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				counters.reusedConns.Add(1)
+			} else {
+				counters.newConns.Add(1)
+			}
+		},
+	}
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	counters.latency.Add(int64(time.Since(started)))
+	if err != nil {
+		counters.errors.Add(1)
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) && !counters.badCert.Swap(true) {
+			log.Printf("TLS certificate of %s is not valid: %v. Set insecure_skip_verify = true in [http_client] section "+
+				"of config to skip verification", req.URL.Hostname(), certErr.Err)
+		}
+	}
+	return resp, err
+}
+
+var sharedTransport http.RoundTripper
+var sharedTransportOnce sync.Once
+
+// Transport returns the transport shared by all outgoing requests: minion api, fetch from templates and extensions,
+// rotation and trade requests. Connections are kept alive and pooled per host.
+func Transport() http.RoundTripper {
+	sharedTransportOnce.Do(func() {
+		cfg := internal.Config.HttpClient
+		sharedTransport = &statsTransport{base: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           countingDialer,
+			ForceAttemptHTTP2:     !cfg.DisableHttp2,
+			MaxIdleConns:          cfg.MaxIdleConns,
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cfg.MaxConnsPerHost,
+			IdleConnTimeout:       time.Duration(cfg.IdleConnTimeout),
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+			TLSClientConfig:       &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+		}}
+	})
+	return sharedTransport
+}
+
+// HttpClient returns a client using the shared transport.
+func HttpClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: Transport(),
+		Timeout:   timeout,
+	}
+}
+
+// DestinationTimeout returns timeout configured for the host in [http_client.timeouts] or defaultTimeout.
+func DestinationTimeout(host string, defaultTimeout time.Duration) time.Duration {
+	if t, ok := internal.Config.HttpClient.Timeouts[strings.ToLower(host)]; ok && t > 0 {
+		return time.Duration(t)
+	}
+	return defaultTimeout
+}
+
+// TransportStats returns connection pool statistics per destination host.
+func TransportStats() map[string]HostPoolStats {
+	stats := make(map[string]HostPoolStats)
+	poolCounters.Range(func(key, value any) bool {
+		c := value.(*hostPoolCounters)
+		s := HostPoolStats{
+			Requests:    c.requests.Load(),
+			Errors:      c.errors.Load(),
+			NewConns:    c.newConns.Load(),
+			ReusedConns: c.reusedConns.Load(),
+			OpenConns:   c.openConns.Load(),
+		}
+		if s.Requests > 0 {
+			s.AvgLatencyMs = float64(c.latency.Load()) / float64(s.Requests) / float64(time.Millisecond)
+		}
+		stats[key.(string)] = s
+		return true
+	})
+	return stats
+}
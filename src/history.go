@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+)
+
+type HistoryCmd struct {
+	List struct {
+		Site string `arg:"" help:"hostname of the site"`
+		File string `arg:"" optional:"" help:"file relative to the site directory, like templates/category.twig; all files by default"`
+	} `cmd:"" help:"List saved versions of site configs and templates"`
+	Diff struct {
+		Site string `arg:"" help:"hostname of the site"`
+		File string `arg:"" help:"file relative to the site directory"`
+		From string `name:"from" help:"version hash prefix or time; the version before the newest one by default"`
+		To   string `name:"to" help:"version hash prefix or time; the current file by default"`
+	} `cmd:"" help:"Show diff of two versions of the site file"`
+	Rollback struct {
+		Site string `arg:"" help:"hostname of the site"`
+		File string `arg:"" optional:"" help:"file relative to the site directory; the whole site by default"`
+		To   string `name:"to" required:"" help:"version hash prefix or time, like \"2024-05-01 12:00\"; the whole site can be rolled back only to time"`
+	} `cmd:"" help:"Roll back the site file or the whole site to the version"`
+}
+
+// History lists, diffs and rolls back versions of site files. Running server is asked through admin api,
+// as the database is locked by it. Without admin_route the database is opened directly.
+func History(command string) {
+	var host, file string
+	var method, action string
+	q := url.Values{}
+	switch {
+	case strings.HasPrefix(command, "history list"):
+		host, file = CLI.History.List.Site, CLI.History.List.File
+		method = http.MethodGet
+	case strings.HasPrefix(command, "history diff"):
+		host, file = CLI.History.Diff.Site, CLI.History.Diff.File
+		method, action = http.MethodGet, "/diff"
+		q.Set("from", CLI.History.Diff.From)
+		q.Set("to", CLI.History.Diff.To)
+	case strings.HasPrefix(command, "history rollback"):
+		host, file = CLI.History.Rollback.Site, CLI.History.Rollback.File
+		method, action = http.MethodPost, "/rollback"
+		q.Set("to", CLI.History.Rollback.To)
+	}
+	host = internal.NormalizeHost(host)
+	sitePath := filepath.Join(internal.Config.Frontend.SitesPath, host)
+	if _, err := os.Stat(filepath.Join(sitePath, "config.toml")); err != nil {
+		fmt.Println("Can't find site", host, "in", internal.Config.Frontend.SitesPath)
+		os.Exit(1)
+	}
+	// files are relative to the site directory, but paths relative to the current directory work too
+	if file != "" {
+		if abs, err := filepath.Abs(file); err == nil {
+			if absSitePath, _ := filepath.Abs(sitePath); strings.HasPrefix(abs, absSitePath+string(filepath.Separator)) {
+				file, _ = filepath.Rel(absSitePath, abs)
+			}
+		}
+		q.Set("file", filepath.ToSlash(file))
+	}
+	body, status, err := historyRequest(method, host, action, q)
+	if err != nil {
+		if internal.Config.General.AdminRoute != "" {
+			fmt.Println("server is not available, opening the database:", err)
+		}
+		body, status = historyLocal(sitePath, action, q)
+	}
+	if status != http.StatusOK {
+		fmt.Println(strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+	switch action {
+	case "":
+		var versions []db.FileVersion
+		if err = json.Unmarshal(body, &versions); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		printVersions(versions)
+	case "/diff":
+		fmt.Print(string(body))
+	case "/rollback":
+		var result struct {
+			Restored []string `json:"restored"`
+		}
+		if err = json.Unmarshal(body, &result); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(result.Restored) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+		for _, f := range result.Restored {
+			fmt.Println("restored", f)
+		}
+	}
+}
+
+// historyRequest calls admin api of the running server.
+func historyRequest(method, host, action string, q url.Values) (body []byte, status int, err error) {
+	if internal.Config.General.AdminRoute == "" {
+		return nil, 0, fmt.Errorf("admin_route is not set")
+	}
+	u := fmt.Sprintf("http://127.0.0.1:%d%s/history/%s%s?%s", internal.Config.General.Port,
+		strings.TrimSuffix(internal.Config.General.AdminRoute, "/"), url.PathEscape(host), action, q.Encode())
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+internal.Config.Frontend.SecretKey)
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// historyLocal does the same as admin api with the database opened directly.
+func historyLocal(sitePath, action string, q url.Values) (body []byte, status int) {
+	db.InitDB()
+	defer db.BeforeClose()
+	var result any
+	var err error
+	switch action {
+	case "":
+		var versions []db.FileVersion
+		versions, err = site.History(sitePath, q.Get("file"))
+		result = versions
+	case "/diff":
+		var diff string
+		if diff, err = site.HistoryDiff(sitePath, q.Get("file"), q.Get("from"), q.Get("to")); err == nil {
+			return []byte(diff), http.StatusOK
+		}
+	case "/rollback":
+		var restored []string
+		restored, err = site.HistoryRollback(sitePath, q.Get("file"), q.Get("to"))
+		result = map[string]any{"restored": restored}
+	}
+	if err != nil {
+		return []byte(err.Error()), http.StatusBadRequest
+	}
+	body, _ = json.Marshal(result)
+	return body, http.StatusOK
+}
+
+func printVersions(versions []db.FileVersion) {
+	if len(versions) == 0 {
+		fmt.Println("no saved versions")
+		return
+	}
+	for _, v := range versions {
+		hash := "deleted     "
+		if !v.Deleted {
+			hash = v.Hash[:12]
+		}
+		fmt.Printf("%s  %s  %8d  %s\n", v.Time.Local().Format("2006-01-02 15:04:05"), hash, v.Size, v.File)
+	}
+}
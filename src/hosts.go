@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// hostsTable maps request hosts to site routers: exact hosts first, then alias patterns in order.
+type hostsTable struct {
+	exact    map[string]*hostRouter
+	patterns []hostPattern
+}
+
+// hostPattern is wildcard or regex alias of the site.
+type hostPattern struct {
+	alias  string
+	re     *regexp.Regexp
+	router *hostRouter
+}
+
+// compileHostPattern compiles alias with * wildcard or regex prefixed with ~. Returns nil for exact hosts.
+// * matches one or more labels of the subdomain. Regex is anchored, it matches the whole host.
+func compileHostPattern(alias string) (*regexp.Regexp, error) {
+	switch {
+	case strings.HasPrefix(alias, "~"):
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(alias, "~") + ")$")
+		return re, errors.Wrap(err, "alias "+alias)
+	case strings.Contains(alias, "*"):
+		alias = internal.NormalizeHost(alias)
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(alias), `\*`, `([a-z0-9-]+(?:\.[a-z0-9-]+)*)`)
+		return regexp.Compile("^" + pattern + "$")
+	}
+	return nil, nil
+}
+
+// lookup returns router of the host and the subdomain matched by the alias pattern.
+func (t hostsTable) lookup(host string) (*hostRouter, string) {
+	if hr := t.exact[host]; hr != nil {
+		return hr, siteSubdomain(hr, host)
+	}
+	for _, p := range t.patterns {
+		m := p.re.FindStringSubmatch(host)
+		if m == nil {
+			continue
+		}
+		if i := p.re.SubexpIndex("subdomain"); i > 0 {
+			return p.router, m[i]
+		}
+		if len(m) > 1 {
+			return p.router, m[1]
+		}
+		return p.router, siteSubdomain(p.router, host)
+	}
+	return nil, ""
+}
+
+// siteSubdomain returns the part of host before the site host, like "m" for m.example.com.
+func siteSubdomain(hr *hostRouter, host string) string {
+	siteHost := "." + internal.NormalizeHost(filepath.Base(hr.path))
+	if strings.HasSuffix(host, siteHost) {
+		return strings.TrimSuffix(host, siteHost)
+	}
+	return ""
+}
+
+// canonicalRedirect redirects to the host with or without www, as set by canonical_host of the site config.
+// Only the site host (directory name) and its www version are redirected, not aliases or language domains.
+// Returns true if redirect happened.
+func canonicalRedirect(w http.ResponseWriter, r *http.Request, config *types.Config) bool {
+	if config.CanonicalHost == "" {
+		return false
+	}
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return false
+	}
+	apex := internal.NormalizeHost(config.Hostname)
+	if host != apex && host != "www."+apex {
+		return false
+	}
+	var target string
+	switch config.CanonicalHost {
+	case "apex":
+		if strings.HasPrefix(host, "www.") {
+			target = strings.TrimPrefix(host, "www.")
+		}
+	case "www":
+		if !strings.HasPrefix(host, "www.") {
+			target = "www." + host
+		}
+	}
+	if target == "" {
+		return false
+	}
+	scheme := "http://"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https://"
+	}
+	http.Redirect(w, r, scheme+target+r.URL.RequestURI(), http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+func TestCompileHostPattern(t *testing.T) {
+	tests := []struct {
+		alias   string
+		exact   bool
+		match   []string
+		noMatch []string
+		err     bool
+	}{
+		{alias: "m.example.com", exact: true},
+		{alias: "*.example.com", match: []string{"m.example.com", "a.b.example.com", "www.m.example.com"},
+			noMatch: []string{"example.com", "m.example.com.evil.net", "mexample.com"}},
+		{alias: "*.EXAMPLE.com", match: []string{"m.example.com"}},
+		{alias: `~[a-z]+-city\.example\.net`, match: []string{"moscow-city.example.net"},
+			noMatch: []string{"evil.moscow-city.example.net", "moscow-city.example.net.evil.com"}},
+		{alias: `~^[a-z]+\.example\.org$`, match: []string{"m.example.org"}, noMatch: []string{"a.m.example.org"}},
+		{alias: `~a|b\.example\.org`, match: []string{"a", "b.example.org"}, noMatch: []string{"ab.example.org"}},
+		{alias: "~[", err: true},
+	}
+	for _, tt := range tests {
+		re, err := compileHostPattern(tt.alias)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: no error", tt.alias)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.alias, err)
+			continue
+		}
+		if (re == nil) != tt.exact {
+			t.Errorf("%s: pattern = %v", tt.alias, re)
+			continue
+		}
+		for _, host := range tt.match {
+			if !re.MatchString(host) {
+				t.Errorf("%s doesn't match %s", tt.alias, host)
+			}
+		}
+		for _, host := range tt.noMatch {
+			if re.MatchString(host) {
+				t.Errorf("%s matches %s", tt.alias, host)
+			}
+		}
+	}
+}
+
+func TestHostsTableLookup(t *testing.T) {
+	site := &hostRouter{path: "/sites/example.com"}
+	other := &hostRouter{path: "/sites/other.net"}
+	pattern := func(alias string, hr *hostRouter) hostPattern {
+		re, err := compileHostPattern(alias)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hostPattern{alias: alias, re: re, router: hr}
+	}
+	table := hostsTable{
+		exact: map[string]*hostRouter{"example.com": site, "m.example.com": site, "other.net": other},
+		patterns: []hostPattern{
+			pattern(`~(?P<subdomain>[a-z]+)-city\.other\.net`, other),
+			pattern(`~([a-z]+)\.region\.other\.net`, other),
+			pattern("*.example.com", site),
+			pattern("*.other.net", other),
+		},
+	}
+	tests := []struct {
+		host      string
+		router    *hostRouter
+		subdomain string
+	}{
+		{host: "example.com", router: site},
+		{host: "m.example.com", router: site, subdomain: "m"},
+		{host: "a.b.example.com", router: site, subdomain: "a.b"},
+		{host: "moscow-city.other.net", router: other, subdomain: "moscow"},
+		{host: "north.region.other.net", router: other, subdomain: "north"},
+		{host: "www.other.net", router: other, subdomain: "www"},
+		{host: "example.net", router: nil},
+		{host: "example.com.evil.net", router: nil},
+	}
+	for _, tt := range tests {
+		router, subdomain := table.lookup(tt.host)
+		if router != tt.router || subdomain != tt.subdomain {
+			t.Errorf("lookup(%s) = %v, %q, want %v, %q", tt.host, router, subdomain, tt.router, tt.subdomain)
+		}
+	}
+}
+
+func TestCanonicalRedirect(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical string
+		url       string
+		https     bool
+		proto     string
+		want      string
+	}{
+		{name: "apex redirects www", canonical: "apex", url: "http://www.example.com/a?b=1", https: true,
+			want: "https://example.com/a?b=1"},
+		{name: "apex keeps apex", canonical: "apex", url: "http://example.com/"},
+		{name: "www redirects apex", canonical: "www", url: "http://example.com/a", proto: "https",
+			want: "https://www.example.com/a"},
+		{name: "www keeps www", canonical: "www", url: "http://www.example.com/"},
+		{name: "http is kept", canonical: "www", url: "http://example.com/a", proto: "http", want: "http://www.example.com/a"},
+		{name: "http without proxy header", canonical: "apex", url: "http://www.example.com/a", want: "http://example.com/a"},
+		{name: "port is dropped", canonical: "apex", url: "http://www.example.com:8080/", https: true,
+			want: "https://example.com/"},
+		{name: "alias is not redirected", canonical: "www", url: "http://m.example.com/"},
+		{name: "www alias is not redirected", canonical: "apex", url: "http://www.m.example.com/"},
+		{name: "language domain is not redirected", canonical: "www", url: "http://example.de/"},
+		{name: "ip is not redirected", canonical: "www", url: "http://127.0.0.1/"},
+		{name: "not set", url: "http://www.example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{Hostname: "example.com", CanonicalHost: tt.canonical}
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.https {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			redirected := canonicalRedirect(w, r, config)
+			if redirected != (tt.want != "") {
+				t.Fatalf("redirected = %v, want %v", redirected, tt.want != "")
+			}
+			if !redirected {
+				return
+			}
+			if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.want {
+				t.Fatalf("%d %s, want 301 %s", w.Code, w.Header().Get("Location"), tt.want)
+			}
+		})
+	}
+}
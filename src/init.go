@@ -3,14 +3,57 @@ package main
 import (
 	"log"
 
+	"github.com/pkg/errors"
+
 	"sersh.com/totaltube/frontend/api"
+	"sersh.com/totaltube/frontend/db"
 	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
 )
 
+func initOptions() {
+	var err error
+	internal.Config.Options, err = api.Options(nil)
+	if err != nil {
+		if !internal.Config.Offline.Enable {
+			panic(errors.Wrap(err, "Can't get sites options"))
+		}
+		log.Println("Can't get sites options, starting in offline mode:", err)
+		api.MarkUnavailable()
+		return
+	}
+	api.NegotiateApiVersion(internal.Config.Options)
+}
+
+// initLastKnownOptions stores minion options to database or, in offline mode, loads the last known ones.
+func initLastKnownOptions() {
+	if internal.Config.Options != nil {
+		if err := db.SaveLastKnown("options", internal.Config.Options); err != nil {
+			log.Println("Can't save minion options:", err)
+		}
+		return
+	}
+	options := new(internal.Options)
+	if err := db.LoadLastKnown("options", options); err != nil {
+		log.Fatalln("Can't get sites options from api or database:", err)
+	}
+	internal.Config.Options = options
+	api.NegotiateApiVersion(options)
+}
+
 func initLanguages() {
 	languages, err := api.Languages(nil)
 	if err != nil {
-		log.Fatalln("Can't get languages from api:", err)
+		if !internal.Config.Offline.Enable {
+			log.Fatalln("Can't get languages from api:", err)
+		}
+		if e := db.LoadLastKnown("languages", &languages); e != nil {
+			log.Fatalln("Can't get languages from api or database:", err, e)
+		}
+		log.Println("Can't get languages from api, using last known:", err)
+		api.MarkUnavailable()
+	} else if err = db.SaveLastKnown("languages", languages); err != nil {
+		log.Println("Can't save languages:", err)
 	}
 	internal.InitLanguages(languages)
 }
@@ -18,8 +61,15 @@ func initLanguages() {
 func initCountryGroups() {
 	countryGroups, err := api.CountryGroups()
 	if err != nil {
-		log.Println("Can't get country group info from api:", err)
-		return
+		var lastKnown []types.CountryGroup
+		if !internal.Config.Offline.Enable || db.LoadLastKnown("country_groups", &lastKnown) != nil {
+			log.Println("Can't get country group info from api:", err)
+			return
+		}
+		log.Println("Can't get country group info from api, using last known:", err)
+		countryGroups = lastKnown
+	} else if err = db.SaveLastKnown("country_groups", countryGroups); err != nil {
+		log.Println("Can't save country groups:", err)
 	}
 	internal.InitCountryGroups(countryGroups)
-}
\ No newline at end of file
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"sync/atomic"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+const DefaultApiVersion = "v1"
+
+var negotiatedApiVersion atomic.Value
+
+// version from the end of api_url, used until the version is negotiated
+var apiUrlVersion string
+
+// SetNegotiatedApiVersion stores api version agreed with the minion on startup.
+func SetNegotiatedApiVersion(version string) {
+	negotiatedApiVersion.Store(version)
+}
+
+// ApiVersion returns api version for requests of the site: site api_version, then global api_version,
+// then the negotiated one and finally the version from api_url.
+func ApiVersion(config *types.Config) string {
+	if config != nil && config.General.ApiVersion != "" {
+		return config.General.ApiVersion
+	}
+	if Config.General.ApiVersion != "" {
+		return Config.General.ApiVersion
+	}
+	if v, ok := negotiatedApiVersion.Load().(string); ok && v != "" {
+		return v
+	}
+	if apiUrlVersion != "" {
+		return apiUrlVersion
+	}
+	return DefaultApiVersion
+}
+
+// StrictApiDecoding returns true if api responses should be checked against the expected schema.
+func StrictApiDecoding() bool {
+	return Config.General.StrictApiDecoding || Config.General.Development
+}
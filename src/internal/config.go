@@ -25,6 +25,8 @@ type (
 		Mail          Mail
 		Comments      Comments
 		Related       Related
+		HttpClient    HttpClient                   `toml:"http_client"`
+		Offline       Offline                      `toml:"offline"`
 		CacheTimeouts CacheTimeouts                `toml:"cache_timeouts"`
 		Translations  map[string]map[string]string `toml:"translations"`
 		Custom        map[string]string            `toml:"custom"`
@@ -39,6 +41,8 @@ type (
 		UseIpV6Network                     bool           `toml:"use_ipv6_network"`
 		ApiUrl                             string         `toml:"api_url"`
 		ApiSecret                          string         `toml:"api_secret"`
+		ApiVersion                         string         `toml:"api_version"`
+		StrictApiDecoding                  bool           `toml:"strict_api_decoding"`
 		ApiTimeout                         types.Duration `toml:"api_timeout"`
 		LangCookie                         string         `toml:"lang_cookie"`
 		RecreateWorkers                    uint16         `toml:"recreate_workers"`
@@ -55,6 +59,7 @@ type (
 		DebugRoute                         string         `toml:"debug_route"`
 		TranslateStreams                   uint16         `toml:"translate_streams"` // number of simultaneous streams for translation
 		CanonicalNoPagination              bool           `toml:"canonical_no_pagination"`
+		AdminRoute                         string         `toml:"admin_route"` // admin api, authorized with secret_key
 	}
 	Frontend struct {
 		SitesPath                string   `toml:"sites_path"`
@@ -105,6 +110,20 @@ type (
 		TagsMaxQueryTerms            *int     `toml:"tags_max_query_terms"`
 		TagsBoost                    *float64 `toml:"tags_boost"`
 	}
+	HttpClient struct {
+		MaxIdleConns        int                       `toml:"max_idle_conns"`
+		MaxIdleConnsPerHost int                       `toml:"max_idle_conns_per_host"`
+		MaxConnsPerHost     int                       `toml:"max_conns_per_host"`
+		IdleConnTimeout     types.Duration            `toml:"idle_conn_timeout"`
+		DisableHttp2        bool                      `toml:"disable_http2"`
+		InsecureSkipVerify  bool                      `toml:"insecure_skip_verify"`
+		Timeouts            map[string]types.Duration `toml:"timeouts"` // timeouts per destination host
+		StatsRoute          string                    `toml:"stats_route"`
+	}
+	Offline struct {
+		Enable    bool           `toml:"enable"`     // serve the site from cache when minion is not available
+		KeepStale types.Duration `toml:"keep_stale"` // how long expired cache is kept for offline mode
+	}
 	CacheTimeouts struct {
 		ContentItem             types.Duration `toml:"content_item"`
 		Search                  types.Duration `toml:"search"`
@@ -130,7 +149,7 @@ type (
 	}
 )
 
-var apiVersionRegex = regexp.MustCompile(`^(.*)/v\d+/?$`)
+var apiVersionRegex = regexp.MustCompile(`^(.*)/(v\d+)/?$`)
 
 func InitConfig(configPath string) {
 	Config = &ConfigT{
@@ -163,6 +182,15 @@ func InitConfig(configPath string) {
 			ItemsPerPage: 30,
 			MaxReplies:   200,
 		},
+		HttpClient: HttpClient{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: 200,
+			MaxConnsPerHost:     2000,
+			IdleConnTimeout:     types.Duration(time.Second * 90),
+		},
+		Offline: Offline{
+			KeepStale: types.Duration(time.Hour * 24),
+		},
 		CacheTimeouts: CacheTimeouts{
 			Category:                types.Duration(time.Minute * 3),
 			CategoryPagination:      types.Duration(time.Minute * 30),
@@ -204,6 +232,7 @@ func InitConfig(configPath string) {
 	matches := apiVersionRegex.FindStringSubmatch(Config.General.ApiUrl)
 	if matches != nil {
 		Config.General.ApiUrl = matches[1] + "/"
+		apiUrlVersion = matches[2]
 	}
 	Config.MainPath = filepath.Dir(configPath)
 	if Config.General.TranslateStreams < 1 || Config.General.TranslateStreams > 1000 {
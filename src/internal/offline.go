@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+var offline atomic.Bool
+
+// IsOffline returns true if minion is not available and the sites are served from cache.
+func IsOffline() bool {
+	return offline.Load()
+}
+
+// SetOffline switches offline mode. It does nothing if offline mode is disabled in config.
+func SetOffline(value bool) {
+	if value && !Config.Offline.Enable {
+		return
+	}
+	if offline.Swap(value) != value {
+		if value {
+			log.Println("minion api is not available, switching to offline mode")
+		} else {
+			log.Println("minion api is available again, leaving offline mode")
+		}
+	}
+}
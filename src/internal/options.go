@@ -34,4 +34,5 @@ type Options struct {
 	RelatedMinSearches      int               `json:"related_min_searches"`      // Минимальное количество поисков по запросу для появления его в related
 	AutocompleteMinTotal    int               `json:"autocomplete_min_total"`    // Минимальное количество контента, соответствующее поисковому запросу для добавления этого запроса в автокомплит
 	AutocompleteMinSearches int               `json:"autocomplete_min_searches"` // Минимальное количество поисков по поисковому запросу для добавления запроса в автокомплит
+	ApiVersions             []string          `json:"api_versions,omitempty"`    // версии api, которые поддерживает minion
 }
@@ -16,6 +16,11 @@ import (
 
 var configsMap = make(map[string]*types.Config)
 var configsMutex sync.RWMutex
+var configWatchers = make(map[string]chan struct{}) // closed to stop watching the config
+
+// ConfigFileChanged is called with the site path and the changed config file before the config is reloaded.
+// It's used to save versions of config files to the history.
+var ConfigFileChanged func(sitePath, file string)
 
 func GetConfig(configPath string, updateConfig func(config *types.Config, configSource string) error) *types.Config {
 	configsMutex.Lock()
@@ -87,6 +92,20 @@ func readConfig(configPath string) (config *types.Config, configSource string, e
 	return
 }
 
+// CheckConfig decodes site config and returns keys of config.toml unknown to the frontend.
+func CheckConfig(configPath string) (config *types.Config, undecoded []string, err error) {
+	config = types.NewConfig()
+	config.Hostname = filepath.Base(filepath.Dir(configPath))
+	var md toml.MetaData
+	if md, err = toml.DecodeFile(configPath, config); err != nil {
+		return
+	}
+	for _, key := range md.Undecoded() {
+		undecoded = append(undecoded, key.String())
+	}
+	return
+}
+
 func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config, configSource string) error) *types.Config {
 	config, configSource, err := readConfig(configPath)
 	if err != nil {
@@ -103,8 +122,13 @@ func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config
 			config.Custom[k] = v
 		}
 	}
+	stop := make(chan struct{})
 	configsMutex.Lock()
 	configsMap[configPath] = config
+	if previous, ok := configWatchers[configPath]; ok {
+		close(previous)
+	}
+	configWatchers[configPath] = stop
 	configsMutex.Unlock()
 	go func() {
 		var m sync.Mutex
@@ -116,6 +140,11 @@ func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config
 				log.Printf("config directory %s no longer exists, stopping watch", watchDir)
 				break
 			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
@@ -135,13 +164,21 @@ func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config
 				defer notify.Stop(c)
 				// waiting for signal of file changing
 				for {
-					info := <-c
+					var info notify.EventInfo
+					select {
+					case info = <-c:
+					case <-stop:
+						return
+					}
 					// Check if directory was deleted
 					if _, err := os.Stat(watchDir); os.IsNotExist(err) {
 						log.Printf("config directory %s was deleted, stopping watch", watchDir)
 						return
 					}
 					if filepath.Base(info.Path()) == "config.toml" || filepath.Base(info.Path()) == "config-translations.toml" {
+						if ConfigFileChanged != nil {
+							ConfigFileChanged(watchDir, filepath.Base(info.Path()))
+						}
 						break
 					}
 				}
@@ -153,6 +190,11 @@ func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config
 					time.Sleep(time.Millisecond * 1500)
 					m.Lock()
 					defer m.Unlock()
+					select {
+					case <-stop:
+						return
+					default:
+					}
 					if !lastChange.After(time.Now().Add(-time.Millisecond * 1500)) {
 						// reload config
 						lastChange = time.Now()
@@ -177,3 +219,14 @@ func GetConfigAndWatch(configPath string, updateConfig func(config *types.Config
 	}()
 	return config
 }
+
+// ForgetConfig stops watching the config of the removed site and removes it from loaded configs.
+func ForgetConfig(configPath string) {
+	configsMutex.Lock()
+	defer configsMutex.Unlock()
+	if stop, ok := configWatchers[configPath]; ok {
+		close(stop)
+		delete(configWatchers, configPath)
+	}
+	delete(configsMap, configPath)
+}
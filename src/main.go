@@ -7,11 +7,9 @@ import (
 	"runtime"
 
 	"github.com/alecthomas/kong"
-	"github.com/pkg/errors"
 	"github.com/posener/complete"
 	"github.com/willabides/kongplete"
 
-	"sersh.com/totaltube/frontend/api"
 	"sersh.com/totaltube/frontend/internal"
 )
 
@@ -39,21 +37,37 @@ func main() {
 		log.Println("Initializing configuration...")
 		internal.InitConfig(CLI.Config)
 		log.Println("Initializing minion options...")
-		internal.Config.Options, err = api.Options(nil)
-		if err != nil {
-			panic(errors.Wrap(err, "Can't get sites options"))
-		}
+		initOptions()
 		log.Println("Starting server...")
 		startServer()
 	case "child":
 		log.Println("Initializing configuration...")
 		internal.InitConfig(CLI.Config)
 		log.Println("Initializing minion options...")
-		internal.Config.Options, err = api.Options(nil)
-		if err != nil {
-			panic(errors.Wrap(err, "Can't get sites options"))
-		}
+		initOptions()
 		startChild(true)
+	case "export":
+		log.Println("Initializing configuration...")
+		internal.InitConfig(CLI.Config)
+		log.Println("Initializing minion options...")
+		initOptions()
+		Export()
+	case "check", "check <site>":
+		internal.InitConfig(CLI.Config)
+		Check()
+	case "test-templates", "test-templates <site>":
+		internal.InitConfig(CLI.Config)
+		TestTemplates()
+	case "preview <site>":
+		internal.InitConfig(CLI.Config)
+		Preview()
+	case "promote <site>":
+		internal.InitConfig(CLI.Config)
+		Promote()
+	case "history list <site>", "history list <site> <file>", "history diff <site> <file>",
+		"history rollback <site>", "history rollback <site> <file>":
+		internal.InitConfig(CLI.Config)
+		History(ctx.Command())
 	case "install":
 		Install()
 	case "backup":
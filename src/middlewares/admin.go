@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"sersh.com/totaltube/frontend/internal"
+)
+
+// AdminMiddleware allows requests to admin api only with "Authorization: Bearer <secret_key>" header.
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := internal.Config.Frontend.SecretKey
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
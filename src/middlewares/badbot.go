@@ -15,7 +15,7 @@ func BadBotMiddleware(next http.Handler) http.Handler {
 			if bad, err := db.CheckIfBadBot(ip, r.UserAgent()); err == nil && bad {
 				isSEBot, err := db.CheckIfSeBot(ip)
 				if err == nil && !isSEBot {
-					http.Error(w, "Forbidden", http.StatusForbidden)
+					ErrorPage(w, r, http.StatusForbidden, "bad bot")
 					return
 				}
 			}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	wroteHeader bool
+	notModified bool
+}
+
+// notModified checks request validators against ETag and Last-Modified of the response.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(ims)
+}
+
+func (cw *conditionalWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	if statusCode == http.StatusOK && notModified(cw.r, cw.Header()) {
+		cw.notModified = true
+		cw.Header().Del("Content-Type")
+		cw.Header().Del("Content-Length")
+		cw.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.notModified {
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// ConditionalGetMiddleware answers GET and HEAD requests with 304 Not Modified if If-None-Match or If-Modified-Since
+// of the request match ETag or Last-Modified set by the handler.
+func ConditionalGetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+			(r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == "") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, r: r}, r)
+	})
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionalGetMiddleware(t *testing.T) {
+	const (
+		etag         = `"abc"`
+		lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	)
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		etag         string
+		lastModified string
+		status       int
+		wantStatus   int
+		wantBody     bool
+	}{
+		{name: "no validators in request", method: http.MethodGet, etag: etag, wantStatus: http.StatusOK, wantBody: true},
+		{name: "matching etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": etag}, etag: etag,
+			wantStatus: http.StatusNotModified},
+		{name: "weak etag in request", method: http.MethodGet, headers: map[string]string{"If-None-Match": `W/"abc"`},
+			etag: etag, wantStatus: http.StatusNotModified},
+		{name: "one of etags matches", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"x", "abc"`},
+			etag: etag, wantStatus: http.StatusNotModified},
+		{name: "any etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": "*"}, etag: etag,
+			wantStatus: http.StatusNotModified},
+		{name: "other etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"x"`}, etag: etag,
+			wantStatus: http.StatusOK, wantBody: true},
+		{name: "response without etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": etag},
+			wantStatus: http.StatusOK, wantBody: true},
+		{name: "etag wins over if-modified-since", method: http.MethodGet,
+			headers: map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": lastModified},
+			etag:    etag, lastModified: lastModified, wantStatus: http.StatusOK, wantBody: true},
+		{name: "not modified since", method: http.MethodGet, headers: map[string]string{"If-Modified-Since": lastModified},
+			lastModified: lastModified, wantStatus: http.StatusNotModified},
+		{name: "modified since", method: http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:04 GMT"},
+			lastModified: lastModified, wantStatus: http.StatusOK, wantBody: true},
+		{name: "bad if-modified-since", method: http.MethodGet, headers: map[string]string{"If-Modified-Since": "yesterday"},
+			lastModified: lastModified, wantStatus: http.StatusOK, wantBody: true},
+		{name: "head request", method: http.MethodHead, headers: map[string]string{"If-None-Match": etag}, etag: etag,
+			wantStatus: http.StatusNotModified},
+		{name: "post request", method: http.MethodPost, headers: map[string]string{"If-None-Match": etag}, etag: etag,
+			wantStatus: http.StatusOK, wantBody: true},
+		{name: "error status", method: http.MethodGet, headers: map[string]string{"If-None-Match": etag}, etag: etag,
+			status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantBody: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ConditionalGetMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.lastModified != "" {
+					w.Header().Set("Last-Modified", tt.lastModified)
+				}
+				w.Header().Set("Content-Type", "text/html")
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = w.Write([]byte("page"))
+			}))
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotBody := w.Body.Len() > 0; gotBody != tt.wantBody {
+				t.Fatalf("body sent = %v, want %v", gotBody, tt.wantBody)
+			}
+			if w.Code == http.StatusNotModified && w.Header().Get("Content-Type") != "" {
+				t.Fatal("304 response has Content-Type")
+			}
+		})
+	}
+}
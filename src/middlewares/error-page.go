@@ -0,0 +1,9 @@
+package middlewares
+
+import "net/http"
+
+// ErrorPage outputs error page with the status. It's replaced with the renderer of site templates on start,
+// requests without site get plain status text.
+var ErrorPage = func(w http.ResponseWriter, r *http.Request, status int, message string) {
+	http.Error(w, http.StatusText(status), status)
+}
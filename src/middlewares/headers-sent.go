@@ -28,9 +28,16 @@ func HeadersSent(w http.ResponseWriter) bool {
 	return false
 }
 
+// WithHeadersCheck returns writer HeadersSent works with.
+func WithHeadersCheck(w http.ResponseWriter) http.ResponseWriter {
+	if hcw, ok := w.(*headerCheckWriter); ok {
+		return hcw
+	}
+	return &headerCheckWriter{ResponseWriter: w}
+}
+
 func HeadersSentMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		hcw := &headerCheckWriter{ResponseWriter: w}
-		next.ServeHTTP(hcw, r)
+		next.ServeHTTP(WithHeadersCheck(w), r)
 	})
 }
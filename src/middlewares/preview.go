@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/types"
+)
+
+const PreviewCookie = "tt_preview"
+
+// PreviewMiddleware marks requests with valid preview token in the query param or cookie as preview requests,
+// which are rendered with draft templates. The token from the query is saved to the cookie, so the following
+// pages are previewed too.
+func PreviewMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
+		token := r.URL.Query().Get(config.Params.Preview)
+		if token != "" && helpers.ValidPreviewToken(config.Hostname, token) {
+			expire, _, _ := strings.Cut(token, ".")
+			until, _ := strconv.ParseInt(expire, 10, 64)
+			http.SetCookie(w, &http.Cookie{
+				Name:     PreviewCookie,
+				Value:    token,
+				Path:     "/",
+				Expires:  time.Unix(until, 0),
+				HttpOnly: true,
+			})
+		} else if cookie, err := r.Cookie(PreviewCookie); err == nil && helpers.ValidPreviewToken(config.Hostname, cookie.Value) {
+			token = cookie.Value
+		} else {
+			token = ""
+		}
+		if token != "" {
+			w.Header().Set("Cache-Control", "private, no-store")
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyPreview, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+type rateCounter struct {
+	sync.Mutex
+	window time.Time
+	counts map[string]int // ip -> requests in the window
+}
+
+// rateCounters keeps counters by site and route, so they survive rebuilding of the site router on config reload.
+var rateCounters sync.Map
+
+// RateLimit allows up to perMinute requests per minute from one ip, other requests get 429 with Retry-After header.
+// Requests are counted by key, like host and route name.
+func RateLimit(key string, perMinute int) func(next http.Handler) http.Handler {
+	v, _ := rateCounters.LoadOrStore(key, &rateCounter{window: time.Now().Truncate(time.Minute), counts: make(map[string]int)})
+	counter := v.(*rateCounter)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _ := r.Context().Value(types.ContextKeyIp).(string)
+			now := time.Now()
+			counter.Lock()
+			if current := now.Truncate(time.Minute); current.After(counter.window) {
+				counter.window = current
+				counter.counts = make(map[string]int)
+			}
+			counter.counts[ip]++
+			exceeded := counter.counts[ip] > perMinute
+			window := counter.window
+			counter.Unlock()
+			if exceeded {
+				w.Header().Set("Retry-After", strconv.Itoa(int(window.Add(time.Minute).Sub(now).Seconds())+1))
+				ErrorPage(w, r, http.StatusTooManyRequests, "rate limit")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
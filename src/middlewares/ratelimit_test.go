@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+type rateRequest struct {
+	key  string // counter key of the route
+	ip   string
+	want int
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		requests  []rateRequest
+	}{
+		{
+			name:      "requests above the limit",
+			perMinute: 2,
+			requests: []rateRequest{
+				{key: "a", ip: "1.1.1.1", want: http.StatusOK},
+				{key: "a", ip: "1.1.1.1", want: http.StatusOK},
+				{key: "a", ip: "1.1.1.1", want: http.StatusTooManyRequests},
+				{key: "a", ip: "1.1.1.1", want: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:      "ips are counted separately",
+			perMinute: 1,
+			requests: []rateRequest{
+				{key: "a", ip: "1.1.1.1", want: http.StatusOK},
+				{key: "a", ip: "2.2.2.2", want: http.StatusOK},
+				{key: "a", ip: "1.1.1.1", want: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name:      "routes are counted separately",
+			perMinute: 1,
+			requests: []rateRequest{
+				{key: "a", ip: "1.1.1.1", want: http.StatusOK},
+				{key: "b", ip: "1.1.1.1", want: http.StatusOK},
+				{key: "b", ip: "1.1.1.1", want: http.StatusTooManyRequests},
+			},
+		},
+	}
+	for k, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := "test-" + strconv.Itoa(k) + ":"
+			for i, req := range tt.requests {
+				// handler is created on every request, like the router rebuilt on config reload
+				handler := RateLimit(prefix+req.key, tt.perMinute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyIp, req.ip))
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				if w.Code != req.want {
+					t.Fatalf("request %d: status = %d, want %d", i, w.Code, req.want)
+				}
+				if w.Code != http.StatusTooManyRequests {
+					continue
+				}
+				retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+				if err != nil || retryAfter < 1 || retryAfter > 61 {
+					t.Fatalf("request %d: Retry-After = %q", i, w.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitWindow(t *testing.T) {
+	const key = "test-window"
+	handler := RateLimit(key, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyIp, "1.1.1.1"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	request()
+	if got := request(); got != http.StatusTooManyRequests {
+		t.Fatalf("status = %d in the same minute", got)
+	}
+	// the next minute starts
+	v, _ := rateCounters.Load(key)
+	counter := v.(*rateCounter)
+	counter.Lock()
+	counter.window = counter.window.Add(-time.Minute)
+	counter.Unlock()
+	if got := request(); got != http.StatusOK {
+		t.Fatalf("status = %d in the next minute", got)
+	}
+}
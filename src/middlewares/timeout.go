@@ -12,11 +12,12 @@ func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			w = WithHeadersCheck(w)
 			defer func() {
 				cancel()
-				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-					log.Println("timeout requesting", r.URL.String())
-					w.WriteHeader(http.StatusGatewayTimeout)
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) && !HeadersSent(w) {
+					log.Println("timeout requesting", r.Host+r.URL.String())
+					ErrorPage(w, r, http.StatusGatewayTimeout, "timeout")
 				}
 			}()
 			next.ServeHTTP(w, r.WithContext(ctx))
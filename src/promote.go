@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+)
+
+type PromoteCmd struct {
+	Site  string `arg:"" help:"hostname of the site"`
+	Force bool   `name:"force" help:"promote even if draft templates have errors"`
+}
+
+type PreviewCmd struct {
+	Site string        `arg:"" help:"hostname of the site"`
+	Ttl  time.Duration `name:"ttl" default:"24h" help:"how long the preview link is valid"`
+	Path string        `name:"path" default:"/" help:"page to open"`
+}
+
+// Preview prints the signed link for previewing draft templates of the site.
+func Preview() {
+	opts := CLI.Preview
+	host := internal.NormalizeHost(opts.Site)
+	config, _, err := internal.CheckConfig(filepath.Join(internal.Config.Frontend.SitesPath, host, "config.toml"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if internal.Config.Frontend.SecretKey == "" {
+		fmt.Println("secret_key is not set in the global config")
+		os.Exit(1)
+	}
+	until := time.Now().Add(opts.Ttl)
+	fmt.Printf("https://%s%s?%s=%s\n", host, opts.Path, config.Params.Preview, helpers.PreviewToken(host, until))
+	fmt.Println("valid until", until.Format(time.RFC3339))
+}
+
+// Promote replaces live templates of the site with templates-draft. Previous live templates are kept
+// in templates-previous, and templates-draft is recreated as a copy of the new live templates.
+func Promote() {
+	opts := CLI.Promote
+	sitePath := filepath.Join(internal.Config.Frontend.SitesPath, internal.NormalizeHost(opts.Site))
+	live := filepath.Join(sitePath, site.TemplatesDir)
+	draft := filepath.Join(sitePath, site.DraftTemplatesDir)
+	previous := filepath.Join(sitePath, "templates-previous")
+	if info, err := os.Stat(draft); err != nil || !info.IsDir() {
+		fmt.Println("no", site.DraftTemplatesDir, "directory in", sitePath)
+		os.Exit(1)
+	}
+	if errs := site.CheckDraftTemplates(sitePath); len(errs) > 0 && !opts.Force {
+		for _, file := range sortedKeys(errs) {
+			fmt.Println(file + ": " + errs[file].Error())
+		}
+		fmt.Println("draft templates have errors, use --force to promote anyway")
+		os.Exit(1)
+	}
+	if err := exchangeDirs(draft, live); err != nil {
+		fmt.Println("can't swap", site.DraftTemplatesDir, "and", site.TemplatesDir+":", err)
+		os.Exit(1)
+	}
+	// now draft directory holds previous live templates
+	if err := os.RemoveAll(previous); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := os.Rename(draft, previous); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := copyDir(live, draft); err != nil {
+		fmt.Println("can't recreate", site.DraftTemplatesDir+":", err)
+		os.Exit(1)
+	}
+	fmt.Println("promoted", site.DraftTemplatesDir, "of", filepath.Base(sitePath)+", previous templates are in", filepath.Base(previous))
+}
+
+// renameExchange swaps two directories with three renames, when atomic exchange is not available.
+func renameExchange(a, b string) error {
+	tmp := a + ".swap"
+	if err := os.Rename(a, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(b, a); err != nil {
+		_ = os.Rename(tmp, a)
+		return err
+	}
+	return os.Rename(tmp, b)
+}
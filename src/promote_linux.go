@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// exchangeDirs atomically swaps two directories, so the server never sees the site without templates.
+func exchangeDirs(a, b string) error {
+	if err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE); err != nil {
+		if err == unix.EINVAL || err == unix.ENOSYS {
+			// file system doesn't support exchange
+			return renameExchange(a, b)
+		}
+		return err
+	}
+	return nil
+}
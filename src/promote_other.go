@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+func exchangeDirs(a, b string) error {
+	return renameExchange(a, b)
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,7 +30,95 @@ import (
 type hostRouter struct {
 	configPath string
 	path       string
-	handler    http.Handler
+	handler    atomic.Value // http.Handler, swapped when routes of the config change
+	routes     routesConfig
+	assets     *assetsConfig
+	// watchers started for the site
+	watchJs, watchScss, watchSchedule bool
+}
+
+// routesConfig is the part of site config the router is built from. Routes include language template.
+type routesConfig struct {
+	Routes                    types.ConfigRoutes
+	MultiLanguage             bool
+	NoRedirectDefaultLanguage bool
+	ToplistDataUrl            string
+	SitemapRoute              string
+	LanguageDomains           map[string]string
+	RouteOptions              map[string]types.RouteOptions
+}
+
+func routesSignature(config *types.Config) routesConfig {
+	return routesConfig{
+		Routes:                    config.Routes,
+		MultiLanguage:             config.General.MultiLanguage,
+		NoRedirectDefaultLanguage: config.General.NoRedirectDefaultLanguage,
+		ToplistDataUrl:            config.General.ToplistDataUrl,
+		SitemapRoute:              config.Sitemap.Route,
+		LanguageDomains:           config.LanguageDomains,
+		RouteOptions:              config.RouteOptions,
+	}
+}
+
+// assetsConfig is the part of site config js and css bundles are built from.
+type assetsConfig struct {
+	Javascript types.ConfigJs
+	Scss       types.ConfigScss
+	Schedule   []types.ConfigSchedule
+}
+
+// reload rebuilds the router of the site if the routes are changed in the reloaded config.
+// Requests being served finish with the previous router.
+func (h *hostRouter) reload(config *types.Config) {
+	h.watchAssets(config)
+	routes := routesSignature(config)
+	if reflect.DeepEqual(routes, h.routes) {
+		return
+	}
+	h.routes = routes
+	h.handler.Store(h.buildRouter(config))
+	log.Println("routes of", filepath.Base(h.path), "reloaded")
+}
+
+// watchAssets starts watchers of js and scss directories and of scheduled themes if they are not started yet.
+// Bundles are rebuilt at once when their config is changed.
+func (h *hostRouter) watchAssets(config *types.Config) {
+	assets := assetsConfig{Javascript: config.Javascript, Scss: config.Scss, Schedule: config.Schedule}
+	previous := h.assets
+	h.assets = &assets
+	scheduleChanged := previous != nil && !reflect.DeepEqual(previous.Schedule, assets.Schedule)
+	jsChanged := previous != nil && (scheduleChanged || !reflect.DeepEqual(previous.Javascript, assets.Javascript))
+	scssChanged := previous != nil && (scheduleChanged || !reflect.DeepEqual(previous.Scss, assets.Scss))
+	jsPath := filepath.Join(h.path, "js")
+	if _, err := os.Stat(jsPath); err == nil {
+		if !h.watchJs {
+			h.watchJs = true
+			site.WatchJS(jsPath, h.configPath) // Следить за директорией и пересоздавать js
+		} else if jsChanged {
+			go func() {
+				if err := site.RebuildJS(jsPath, config); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+	}
+	scssPath := filepath.Join(h.path, "scss")
+	if _, err := os.Stat(scssPath); err == nil {
+		if !h.watchScss {
+			h.watchScss = true
+			site.WatchScss(scssPath, h.configPath) // Следить за scss и пересоздавать css
+		} else if scssChanged {
+			go func() {
+				if err := site.RebuildSCSS(scssPath, config); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+	}
+	if len(config.Schedule) > 0 && !h.watchSchedule {
+		h.watchSchedule = true
+		site.WatchSchedule(h.path, h.configPath) // Переключать темы по расписанию
+	}
 }
 
 func fixPageAndIdRoute(pageRoute string) string {
@@ -53,8 +143,8 @@ func normalizeHostHeader(hostHeader string) string {
 }
 
 func InitRouter() http.Handler {
-	var hosts atomic.Value // map[string]*hostRouter
-	hosts.Store(map[string]*hostRouter{})
+	var hosts atomic.Value // hostsTable
+	hosts.Store(hostsTable{})
 
 	var primaryRoutersMu sync.Mutex
 	primaryRouters := map[string]*hostRouter{} // configPath -> router
@@ -82,7 +172,12 @@ func InitRouter() http.Handler {
 		defer primaryRoutersMu.Unlock()
 
 		newHosts := map[string]*hostRouter{}
-		for _, hr := range primaryRouters {
+		var patterns []hostPattern
+		// sorted for the same order of alias patterns on every rebuild
+		configPaths := lo.Keys(primaryRouters)
+		sort.Strings(configPaths)
+		for _, configPath := range configPaths {
+			hr := primaryRouters[configPath]
 			primaryHost := internal.NormalizeHost(filepath.Base(hr.path))
 			if primaryHost == "" {
 				log.Printf("warning: empty host for site directory %q (config %q), skipping", hr.path, hr.configPath)
@@ -94,8 +189,11 @@ func InitRouter() http.Handler {
 			}
 			newHosts[primaryHost] = hr
 
-			// Register additional host aliases from language_domains.
 			cfg := internal.GetConfig(hr.configPath, api.UpdateConfigRetry)
+			if initialized.Load() {
+				hr.reload(cfg)
+			}
+			// Register additional host aliases from language_domains.
 			for langKey, raw := range cfg.LanguageDomains {
 				target, ok := internal.ParseLanguageDomainTarget(raw)
 				if !ok || target == nil {
@@ -111,9 +209,30 @@ func InitRouter() http.Handler {
 				}
 				newHosts[aliasHost] = hr
 			}
+			// Register aliases: exact hosts, wildcards and regular expressions.
+			for _, alias := range cfg.Aliases {
+				re, err := compileHostPattern(alias)
+				if err != nil {
+					log.Printf("warning: bad alias %q from %q: %v", alias, hr.configPath, err)
+					continue
+				}
+				if re != nil {
+					patterns = append(patterns, hostPattern{alias: alias, re: re, router: hr})
+					continue
+				}
+				aliasHost := internal.NormalizeHost(alias)
+				if aliasHost == "" || aliasHost == primaryHost {
+					continue
+				}
+				if existing := newHosts[aliasHost]; existing != nil && existing.configPath != hr.configPath {
+					log.Printf("warning: alias host %q from %q already registered by %q, skipping", aliasHost, hr.configPath, existing.configPath)
+					continue
+				}
+				newHosts[aliasHost] = hr
+			}
 		}
 
-		hosts.Store(newHosts)
+		hosts.Store(hostsTable{exact: newHosts, patterns: patterns})
 	}
 
 	// Debounced rebuild: collapse rapid consecutive config changes into a single hosts-map swap.
@@ -149,325 +268,115 @@ func InitRouter() http.Handler {
 		}
 	}()
 
-	matches, err := filepath.Glob(filepath.Join(internal.Config.Frontend.SitesPath, "*"))
-	if err != nil {
-		log.Fatalln(err)
-	}
-	for _, m := range matches {
-		configPath := filepath.Join(m, "config.toml")
-		if _, err := os.Stat(configPath); err != nil {
-			continue
-		}
-		config := internal.GetConfigAndWatch(configPath, updateFn)
-		h := hostRouter{
-			configPath: configPath,
-			path:       m,
-		}
-		jsPath := filepath.Join(m, "js")
-		if _, err := os.Stat(jsPath); err == nil {
-			site.WatchJS(jsPath, configPath) // Следить за директорией и пересоздавать js
-		}
-		scssPath := filepath.Join(m, "scss")
-		if _, err := os.Stat(scssPath); err == nil {
-			site.WatchScss(scssPath, configPath) // Следить за scss и пересоздавать css
-		}
-		hr := chi.NewRouter()
-		hr.Use(middleware.Recoverer)
-		hr.Use(middleware.Timeout(60 * time.Second))
-		hr.Use(middleware.GetHead)
-		if internal.Config.General.EnableAccessLog {
-			hr.Use(middleware.Logger)
-			log.Println("Using access log")
-		}
-		hr.Use(middleware.StripSlashes)
-		// Handle language domain redirect and set config in context
-		hr.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				config := internal.GetConfig(configPath, api.UpdateConfigRetry)
-				r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyConfig, config))
-				r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyPath, h.path))
-				r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyLang, config.General.DefaultLanguage))
-				if handlers.HandleLanguageDomainRedirect(w, r, config) {
-					return
-				}
-				next.ServeHTTP(w, r)
-			})
-		})
-		dir := http.Dir(filepath.Join(m, "public"))
-		fileServer := http.FileServer(dir)
-		// Serving static if it exists in the public route
-		hr.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if (r.Method == "GET" || r.Method == "") && strings.ContainsRune(r.URL.Path, '.') {
-					if _, err := os.Stat(filepath.Join(m, "public", r.URL.Path)); err == nil {
-						fileServer.ServeHTTP(w, r)
-						return
-					}
-				}
-				next.ServeHTTP(w, r)
-			})
-		})
-		// Can check if headers are sent
-		hr.Use(middlewares.HeadersSentMiddleware)
-		if config.Routes.Rating != "" && config.Routes.Rating != "-" {
-			hr.Handle(fixPageAndIdRoute(config.Routes.Rating), middlewares.BadBotMiddleware(handlers.Rating))
-		}
-		if config.Routes.Comments != "" && config.Routes.Comments != "-" {
-			hr.Handle(fixPageAndIdRoute(config.Routes.Comments), middlewares.BadBotMiddleware(handlers.Comments))
-		}
-		if config.Routes.Autocomplete != "" && config.Routes.Autocomplete != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Autocomplete), config, handlers.Autocomplete)
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Autocomplete), middlewares.BadBotMiddleware(handlers.Autocomplete))
-			}
-		}
-		if config.Routes.Search != "" && config.Routes.Search != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Search), config, handlers.Search)
-				if config.Routes.SearchPagination != "" && config.Routes.SearchPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.SearchPagination), config, handlers.Search)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Search), middlewares.BadBotMiddleware(handlers.Search))
-				if config.Routes.SearchPagination != "" && config.Routes.SearchPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.SearchPagination), middlewares.BadBotMiddleware(handlers.Search))
-				}
-			}
-		}
-		if config.Routes.Category != "" && config.Routes.Category != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Category), config, handlers.Category)
-				if config.Routes.CategoryPagination != "" && config.Routes.CategoryPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.CategoryPagination), config, handlers.Category)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Category), middlewares.BadBotMiddleware(handlers.Category))
-				if config.Routes.CategoryPagination != "" && config.Routes.CategoryPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.CategoryPagination), middlewares.BadBotMiddleware(handlers.Category))
-				}
-			}
-		}
-		if config.Routes.TopCategories != "" && config.Routes.TopCategories != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.TopCategories), config, handlers.TopCategories)
-				if config.Routes.TopCategoriesPagination != "" && config.Routes.TopCategoriesPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.TopCategoriesPagination), config, handlers.TopCategories)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.TopCategories), middlewares.BadBotMiddleware(handlers.TopCategories))
-				if config.Routes.TopCategoriesPagination != "" && config.Routes.TopCategoriesPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.TopCategoriesPagination), middlewares.BadBotMiddleware(handlers.TopCategories))
-				}
-			}
-		}
-		if config.Routes.TopContent != "" && config.Routes.TopContent != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.TopContent), config, handlers.TopContent)
-				if config.Routes.TopContentPagination != "" && config.Routes.TopContentPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.TopContentPagination), config, handlers.TopContent)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.TopContent), middlewares.BadBotMiddleware(handlers.TopContent))
-				if config.Routes.TopContentPagination != "" && config.Routes.TopContentPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.TopContentPagination), middlewares.BadBotMiddleware(handlers.TopContent))
-				}
-			}
-		}
-		if config.Routes.Model != "" && config.Routes.Model != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Model), config, handlers.Model)
-				if config.Routes.ModelPagination != "" && config.Routes.ModelPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.ModelPagination), config, handlers.Model)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Model), middlewares.BadBotMiddleware(handlers.Model))
-				if config.Routes.ModelPagination != "" && config.Routes.ModelPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.ModelPagination), middlewares.BadBotMiddleware(handlers.Model))
-				}
-			}
-		}
-		if config.Routes.Channel != "" && config.Routes.Channel != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Channel), config, handlers.Channel)
-				if config.Routes.ChannelPagination != "" && config.Routes.ChannelPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.ChannelPagination), config, handlers.Channel)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Channel), middlewares.BadBotMiddleware(handlers.Channel))
-				if config.Routes.ChannelPagination != "" && config.Routes.ChannelPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.ChannelPagination), middlewares.BadBotMiddleware(handlers.Channel))
-				}
-			}
-		}
-		if config.Routes.ContentItem != "" && config.Routes.ContentItem != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.ContentItem), config, handlers.ContentItem)
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.ContentItem), middlewares.BadBotMiddleware(handlers.ContentItem))
-			}
-		}
-		if config.Routes.New != "" && config.Routes.New != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.New), config, handlers.New)
-				if config.Routes.NewPagination != "" && config.Routes.NewPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.NewPagination), config, handlers.New)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.New), middlewares.BadBotMiddleware(handlers.New))
-				if config.Routes.NewPagination != "" && config.Routes.NewPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.NewPagination), middlewares.BadBotMiddleware(handlers.New))
-				}
-			}
-		}
-		if config.Routes.Long != "" && config.Routes.Long != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Long), config, handlers.Long)
-				if config.Routes.LongPagination != "" && config.Routes.LongPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.LongPagination), config, handlers.Long)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Long), middlewares.BadBotMiddleware(handlers.Long))
-				if config.Routes.LongPagination != "" && config.Routes.LongPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.LongPagination), middlewares.BadBotMiddleware(handlers.Long))
-				}
-			}
+	// versions of config files are kept in the history
+	internal.ConfigFileChanged = site.SnapshotFile
+	// error pages of middlewares are rendered with site templates
+	middlewares.ErrorPage = handlers.OutputError
+	// syncSites registers sites with config.toml in sites_path and unregisters removed ones
+	var syncMu sync.Mutex
+	configMissing := map[string]bool{} // config paths of registered sites missing on the last check
+	syncSites := func() {
+		syncMu.Lock()
+		defer syncMu.Unlock()
+		matches, err := filepath.Glob(filepath.Join(internal.Config.Frontend.SitesPath, "*"))
+		if err != nil {
+			log.Println(err)
+			return
 		}
-		if config.Routes.Popular != "" && config.Routes.Popular != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Popular), config, handlers.Popular)
-				if config.Routes.PopularPagination != "" && config.Routes.PopularPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.PopularPagination), config, handlers.Popular)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Popular), middlewares.BadBotMiddleware(handlers.Popular))
-				if config.Routes.PopularPagination != "" && config.Routes.PopularPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.PopularPagination), middlewares.BadBotMiddleware(handlers.Popular))
-				}
+		changed := false
+		for _, m := range matches {
+			configPath := filepath.Join(m, "config.toml")
+			if _, err := os.Stat(configPath); err != nil {
+				continue
 			}
-		}
-		if config.Routes.Models != "" && config.Routes.Models != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.Models), config, handlers.Models)
-				if config.Routes.ModelsPagination != "" && config.Routes.ModelsPagination != "-" {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(config.Routes.ModelsPagination), config, handlers.Models)
-				}
-			} else {
-				hr.Handle(fixPageAndIdRoute(config.Routes.Models), middlewares.BadBotMiddleware(handlers.Models))
-				if config.Routes.ModelsPagination != "" && config.Routes.ModelsPagination != "-" {
-					hr.Handle(fixPageAndIdRoute(config.Routes.ModelsPagination), middlewares.BadBotMiddleware(handlers.Models))
-				}
+			primaryRoutersMu.Lock()
+			_, registered := primaryRouters[configPath]
+			primaryRoutersMu.Unlock()
+			if registered {
+				continue
 			}
-		}
-		if config.Routes.Out != "" && config.Routes.Out != "-" {
-			hr.Handle(config.Routes.Out, middlewares.BadBotMiddleware(handlers.Out))
-		}
-		if config.Routes.FakePlayer != "" && config.Routes.FakePlayer != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, config.Routes.FakePlayer, config, handlers.FakePlayer)
-			} else {
-				hr.Handle(config.Routes.FakePlayer, middlewares.BadBotMiddleware(handlers.FakePlayer))
+			if initialized.Load() {
+				log.Println("new site", filepath.Base(m))
 			}
-		}
-		if config.Routes.VideoEmbed != "" && config.Routes.VideoEmbed != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, config.Routes.VideoEmbed, config, handlers.VideoEmbed)
-			} else {
-				hr.Handle(config.Routes.VideoEmbed, middlewares.BadBotMiddleware(handlers.VideoEmbed))
+			config := internal.GetConfigAndWatch(configPath, updateFn)
+			h := &hostRouter{
+				configPath: configPath,
+				path:       m,
 			}
+			h.watchAssets(config)
+			go site.SnapshotSite(m) // Сохранить изменения, сделанные при остановленном сервере
+			site.WatchTemplates(m)
+			h.routes = routesSignature(config)
+			h.handler.Store(h.buildRouter(config))
+			primaryRoutersMu.Lock()
+			primaryRouters[configPath] = h
+			primaryRoutersMu.Unlock()
+			changed = true
 		}
-		if config.Routes.Dmca != "" && config.Routes.Dmca != "-" {
-			if config.General.MultiLanguage {
-				handlers.LangHandlers(hr, config.Routes.Dmca, config, handlers.Dmca)
-			} else {
-				hr.Handle(config.Routes.Dmca, middlewares.BadBotMiddleware(handlers.Dmca))
+		primaryRoutersMu.Lock()
+		for configPath, h := range primaryRouters {
+			if _, err := os.Stat(configPath); err == nil {
+				delete(configMissing, configPath)
+				continue
 			}
-		}
-		if internal.Config.Frontend.RouteRedirectContentItem != "" && internal.Config.Frontend.RouteRedirectContentItem != "-" {
-			hr.Handle(internal.Config.Frontend.RouteRedirectContentItem, handlers.RedirectToContentItem)
-		}
-		if config.Routes.Custom != nil {
-			for templateName, routePath := range config.Routes.Custom {
-				if strings.HasSuffix(templateName, "_multilang") {
-					continue
-				}
-				if strings.HasSuffix(templateName, "_pagination") {
-					continue
-				}
-				tName := templateName
-				paginationRoute, isCustomPaginationTemplate := config.Routes.Custom[tName+"_pagination"]
-				_, isCustomMultilangTemplate := config.Routes.Custom[tName+"_multilang"]
-				if config.General.MultiLanguage && (strings.Contains(routePath, "{lang}") || isCustomMultilangTemplate) {
-					handlers.LangHandlers(hr, fixPageAndIdRoute(routePath), config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						ctx := context.WithValue(r.Context(), types.ContextKeyCustomTemplateName, tName)
-						middlewares.BadBotMiddleware(handlers.Custom).ServeHTTP(w, r.WithContext(ctx))
-					}))
-					if isCustomPaginationTemplate && paginationRoute != "" && paginationRoute != "-" {
-						handlers.LangHandlers(hr, fixPageAndIdRoute(paginationRoute), config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-							ctx := context.WithValue(r.Context(), types.ContextKeyCustomTemplateName, tName)
-							middlewares.BadBotMiddleware(handlers.Custom).ServeHTTP(w, r.WithContext(ctx))
-						}))
-					}
-				} else {
-					hr.Handle(fixPageAndIdRoute(routePath), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						ctx := context.WithValue(r.Context(), types.ContextKeyCustomTemplateName, tName)
-						middlewares.BadBotMiddleware(handlers.Custom).ServeHTTP(w, r.WithContext(ctx))
-					}))
-					if isCustomPaginationTemplate && paginationRoute != "" && paginationRoute != "-" {
-						hr.Handle(fixPageAndIdRoute(paginationRoute), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-							ctx := context.WithValue(r.Context(), types.ContextKeyCustomTemplateName, tName)
-							middlewares.BadBotMiddleware(handlers.Custom).ServeHTTP(w, r.WithContext(ctx))
-						}))
-					}
-				}
+			// config being rewritten can be missing for a moment, so the site with its directory in place
+			// is removed only when the config is missing on two checks in a row
+			if _, err := os.Stat(h.path); err == nil && !configMissing[configPath] {
+				configMissing[configPath] = true
+				continue
 			}
+			delete(configMissing, configPath)
+			delete(primaryRouters, configPath)
+			internal.ForgetConfig(configPath)
+			site.ForgetSite(h.path)
+			handlers.ForgetMaintenance(filepath.Base(h.path))
+			log.Println("site", filepath.Base(h.path), "removed")
+			changed = true
 		}
-		if config.General.ToplistDataUrl == "" {
-			config.General.ToplistDataUrl = internal.Config.General.ToplistDataUrl
-		}
-		if config.General.ToplistDataUrl != "" {
-			hr.Handle(config.General.ToplistDataUrl, middlewares.BadBotMiddleware(handlers.ToplistData))
-		}
-		if config.Sitemap.Route != "" {
-			hr.Handle(config.Sitemap.Route, handlers.Sitemap)
-		}
-		blackholeRoute := internal.Config.General.DefaultBlackholeRoute
-		if config.Routes.Blackhole != "" {
-			blackholeRoute = config.Routes.Blackhole
-		}
-		if blackholeRoute != "" && blackholeRoute != "-" {
-			lo.ForEach(strings.Split(blackholeRoute, ","), func(s string, i int) {
-				route := strings.TrimSpace(s)
-				hr.Handle(route, handlers.Blackhole)
-			})
-		}
-		hr.NotFound(handlers.Handle404)
-		//hr.Handle("/*", handlers.Handle404)
-		h.handler = hr
-		primaryRoutersMu.Lock()
-		primaryRouters[configPath] = &h
 		primaryRoutersMu.Unlock()
+		if changed && initialized.Load() {
+			rebuildHosts()
+		}
 	}
+	syncSites()
 
 	// Initial build: publish hosts map once, then allow hot rebuilds on config reload.
 	rebuildHosts()
 	initialized.Store(true)
+	watchSitesPath(internal.Config.Frontend.SitesPath, syncSites)
 
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
-	r.Use(middlewares.Timeout(10 * time.Second))
+	r.Use(middlewares.Timeout(maxRouteTimeout))
 	if internal.Config.General.DebugRoute != "" {
 		r.Mount(internal.Config.General.DebugRoute, middleware.Profiler())
 	}
+	if internal.Config.General.AdminRoute != "" {
+		r.Route(internal.Config.General.AdminRoute, func(ar chi.Router) {
+			ar.Use(middlewares.AdminMiddleware)
+			ar.Get("/history/{host}", handlers.History)
+			ar.Get("/history/{host}/diff", handlers.HistoryDiff)
+			ar.Post("/history/{host}/rollback", handlers.HistoryRollback)
+			ar.Get("/maintenance/{host}", handlers.MaintenanceStatus)
+			ar.Post("/maintenance/{host}", handlers.MaintenanceSet)
+			ar.Delete("/maintenance/{host}", handlers.MaintenanceReset)
+			if internal.Config.HttpClient.StatsRoute != "" {
+				ar.Get(internal.Config.HttpClient.StatsRoute, handlers.TransportStats)
+			}
+		})
+	}
 	r.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		normalizedHost := normalizeHostHeader(r.Host)
-		hostsMap := hosts.Load().(map[string]*hostRouter)
-		host := hostsMap[normalizedHost]
+		host, subdomain := hosts.Load().(hostsTable).lookup(normalizedHost)
 		if host == nil {
 			http.NotFound(w, r)
 			return
 		}
+		if canonicalRedirect(w, r, internal.GetConfig(host.configPath, api.UpdateConfigRetry)) {
+			return
+		}
 		r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyHostName, normalizedHost))
+		r = r.WithContext(context.WithValue(r.Context(), types.ContextKeySubdomain, subdomain))
 		// get first not empty value
 		ip := r.Header.Get(internal.Config.General.RealIpHeader)
 		if ip == "" {
@@ -483,7 +392,7 @@ func InitRouter() http.Handler {
 			ip = "89.23.44.10"
 		}
 		r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyIp, ip))
-		host.handler.ServeHTTP(w, r)
+		host.handler.Load().(http.Handler).ServeHTTP(w, r)
 	}))
 	if os.Getenv("GO_ENV") == "debug" {
 		r.Mount("/_debug", middleware.Profiler())
@@ -491,3 +400,94 @@ func InitRouter() http.Handler {
 	r.Mount("/__do_backup", handlers.Backup)
 	return r
 }
+
+// buildRouter builds router of the site by the routes of config.
+func (h *hostRouter) buildRouter(config *types.Config) http.Handler {
+	hr := chi.NewRouter()
+	hr.Use(middleware.GetHead)
+	if internal.Config.General.EnableAccessLog {
+		hr.Use(middleware.Logger)
+		log.Println("Using access log")
+	}
+	hr.Use(middleware.StripSlashes)
+	// Handle language domain redirect and set config in context
+	hr.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			config := internal.GetConfig(h.configPath, api.UpdateConfigRetry)
+			if internal.Config.General.Development {
+				// request copy of config carries debug toolbar data to api and template calls
+				requestConfig := *config
+				requestConfig.DebugTrace = new(types.DebugTrace)
+				config = &requestConfig
+			}
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyConfig, config))
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyPath, h.path))
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyLang, config.General.DefaultLanguage))
+			if handlers.HandleLanguageDomainRedirect(w, r, config) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	// panics and timeouts are rendered with error templates of the site. The timeout is the same as of the server
+	// router, so the site 504 page is sent before the plain one.
+	hr.Use(handlers.Recoverer)
+	hr.Use(middlewares.Timeout(maxRouteTimeout))
+	// 503 page while the site is in maintenance, static files included
+	hr.Use(handlers.MaintenanceMiddleware)
+	dir := http.Dir(filepath.Join(h.path, "public"))
+	fileServer := http.FileServer(dir)
+	// Serving static if it exists in the public route
+	hr.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method == "GET" || r.Method == "") && strings.ContainsRune(r.URL.Path, '.') {
+				config := r.Context().Value(types.ContextKeyConfig).(*types.Config)
+				if p, found := site.ThemeStaticPath(h.path, config, r.URL.Path); found {
+					// static overlay of the scheduled theme
+					http.ServeFile(w, r, p)
+					return
+				}
+				if _, err := os.Stat(filepath.Join(h.path, "public", r.URL.Path)); err == nil {
+					fileServer.ServeHTTP(w, r)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	// draft templates preview with signed token
+	hr.Use(middlewares.PreviewMiddleware)
+	// 304 for rendered pages with ETag and Last-Modified
+	hr.Use(middlewares.ConditionalGetMiddleware)
+	// Can check if headers are sent
+	hr.Use(middlewares.HeadersSentMiddleware)
+	for _, route := range builtinRoutes {
+		route.handle(hr, config)
+	}
+	if internal.Config.Frontend.RouteRedirectContentItem != "" && internal.Config.Frontend.RouteRedirectContentItem != "-" {
+		hr.Handle(internal.Config.Frontend.RouteRedirectContentItem, handlers.RedirectToContentItem)
+	}
+	handleCustomRoutes(hr, config)
+	toplistDataUrl := config.General.ToplistDataUrl
+	if toplistDataUrl == "" {
+		toplistDataUrl = internal.Config.General.ToplistDataUrl
+	}
+	if toplistDataUrl != "" {
+		hr.Handle(toplistDataUrl, routeHandler(config, "toplist_data", handlers.ToplistData, true))
+	}
+	if config.Sitemap.Route != "" {
+		hr.Handle(config.Sitemap.Route, routeHandler(config, "sitemap", handlers.Sitemap, false))
+	}
+	blackholeRoute := internal.Config.General.DefaultBlackholeRoute
+	if config.Routes.Blackhole != "" {
+		blackholeRoute = config.Routes.Blackhole
+	}
+	if blackholeRoute != "" && blackholeRoute != "-" {
+		lo.ForEach(strings.Split(blackholeRoute, ","), func(s string, i int) {
+			route := strings.TrimSpace(s)
+			hr.Handle(route, handlers.Blackhole)
+		})
+	}
+	hr.NotFound(handlers.Handle404)
+	return hr
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"sersh.com/totaltube/frontend/handlers"
+	"sersh.com/totaltube/frontend/middlewares"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// maxRouteTimeout is the timeout of all requests to the server, timeout of the route can only be shorter.
+const maxRouteTimeout = 10 * time.Second
+
+// siteRoute is the built-in route of the site. Its name is the key of [routes] and [route_options].
+type siteRoute struct {
+	name       string
+	template   string // template the route is rendered with, empty for routes without templates
+	pattern    func(r *types.ConfigRoutes) string
+	pagination func(r *types.ConfigRoutes) string // nil for routes without pagination
+	handler    http.Handler
+	multilang  bool // has language versions on multi-language sites
+	numeric    bool // {page} and {id} match only numbers
+	write      bool // changes data, closed while the site is read-only
+}
+
+// builtinRoutes are registered in this order.
+var builtinRoutes = []siteRoute{
+	{name: "rating", handler: handlers.Rating, numeric: true, write: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.Rating }},
+	{name: "comments", handler: handlers.Comments, numeric: true, write: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.Comments }},
+	{name: "autocomplete", handler: handlers.Autocomplete, multilang: true, numeric: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.Autocomplete }},
+	{name: "search", template: "search", handler: handlers.Search, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Search },
+		pagination: func(r *types.ConfigRoutes) string { return r.SearchPagination }},
+	{name: "category", template: "category", handler: handlers.Category, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Category },
+		pagination: func(r *types.ConfigRoutes) string { return r.CategoryPagination }},
+	{name: "top_categories", template: "top-categories", handler: handlers.TopCategories, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.TopCategories },
+		pagination: func(r *types.ConfigRoutes) string { return r.TopCategoriesPagination }},
+	{name: "top_content", template: "top-content", handler: handlers.TopContent, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.TopContent },
+		pagination: func(r *types.ConfigRoutes) string { return r.TopContentPagination }},
+	{name: "model", template: "model", handler: handlers.Model, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Model },
+		pagination: func(r *types.ConfigRoutes) string { return r.ModelPagination }},
+	{name: "channel", template: "channel", handler: handlers.Channel, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Channel },
+		pagination: func(r *types.ConfigRoutes) string { return r.ChannelPagination }},
+	{name: "content_item", template: "content-item", handler: handlers.ContentItem, multilang: true, numeric: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.ContentItem }},
+	{name: "new", template: "new", handler: handlers.New, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.New },
+		pagination: func(r *types.ConfigRoutes) string { return r.NewPagination }},
+	{name: "long", template: "long", handler: handlers.Long, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Long },
+		pagination: func(r *types.ConfigRoutes) string { return r.LongPagination }},
+	{name: "popular", template: "popular", handler: handlers.Popular, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Popular },
+		pagination: func(r *types.ConfigRoutes) string { return r.PopularPagination }},
+	{name: "models", template: "models", handler: handlers.Models, multilang: true, numeric: true,
+		pattern:    func(r *types.ConfigRoutes) string { return r.Models },
+		pagination: func(r *types.ConfigRoutes) string { return r.ModelsPagination }},
+	{name: "out", handler: handlers.Out,
+		pattern: func(r *types.ConfigRoutes) string { return r.Out }},
+	{name: "fake_player", template: "fake-player", handler: handlers.FakePlayer, multilang: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.FakePlayer }},
+	{name: "video_embed", template: "video-embed", handler: handlers.VideoEmbed, multilang: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.VideoEmbed }},
+	{name: "dmca", template: "dmca", handler: handlers.Dmca, multilang: true,
+		pattern: func(r *types.ConfigRoutes) string { return r.Dmca }},
+}
+
+func routeEnabled(route string) bool {
+	return route != "" && route != "-"
+}
+
+// patterns returns enabled route and pagination route patterns ready for chi.
+func (sr siteRoute) patterns(config *types.Config) (patterns []string) {
+	add := func(route string) {
+		if !routeEnabled(route) {
+			return
+		}
+		if sr.numeric {
+			route = fixPageAndIdRoute(route)
+		}
+		patterns = append(patterns, route)
+	}
+	add(sr.pattern(&config.Routes))
+	if sr.pagination != nil && len(patterns) > 0 {
+		add(sr.pagination(&config.Routes))
+	}
+	return
+}
+
+// handle registers the route with its options on the site router.
+func (sr siteRoute) handle(hr *chi.Mux, config *types.Config) {
+	handler := sr.handler
+	if sr.write {
+		handler = handlers.MaintenanceWrites(handler)
+	}
+	handler = routeHandler(config, sr.name, handler, true)
+	for _, pattern := range sr.patterns(config) {
+		if sr.multilang && config.General.MultiLanguage {
+			handlers.LangHandlers(hr, pattern, config, handler)
+		} else {
+			hr.Handle(pattern, handler)
+		}
+	}
+}
+
+// handleCustomRoutes registers custom routes of the site, they are rendered with custom-<name> templates.
+func handleCustomRoutes(hr *chi.Mux, config *types.Config) {
+	for templateName, routePath := range config.Routes.Custom {
+		if strings.HasSuffix(templateName, "_multilang") || strings.HasSuffix(templateName, "_pagination") {
+			continue
+		}
+		tName := templateName
+		handler := routeHandler(config, tName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), types.ContextKeyCustomTemplateName, tName)
+			handlers.Custom.ServeHTTP(w, r.WithContext(ctx))
+		}), true)
+		patterns := []string{fixPageAndIdRoute(routePath)}
+		if paginationRoute, ok := config.Routes.Custom[tName+"_pagination"]; ok && routeEnabled(paginationRoute) {
+			patterns = append(patterns, fixPageAndIdRoute(paginationRoute))
+		}
+		_, isCustomMultilangTemplate := config.Routes.Custom[tName+"_multilang"]
+		for _, pattern := range patterns {
+			if config.General.MultiLanguage && (strings.Contains(routePath, "{lang}") || isCustomMultilangTemplate) {
+				handlers.LangHandlers(hr, pattern, config, handler)
+			} else {
+				hr.Handle(pattern, handler)
+			}
+		}
+	}
+}
+
+// routeHandler wraps the handler of the route with middlewares set up by [route_options.<name>] of the site config.
+// Bad bots check is on unless the route disables it, badBot is the default for the route.
+func routeHandler(config *types.Config, name string, handler http.Handler, badBot bool) http.Handler {
+	options := config.RouteOptions[name]
+	if options.BadBot != nil {
+		badBot = *options.BadBot
+	}
+	var chain chi.Middlewares
+	if options.RateLimit > 0 {
+		chain = append(chain, middlewares.RateLimit(config.Hostname+":"+name, options.RateLimit))
+	}
+	if timeout := time.Duration(options.Timeout); timeout > maxRouteTimeout {
+		log.Println(config.Hostname, "route_options."+name+".timeout", timeout, "is ignored, it can't be longer than",
+			maxRouteTimeout)
+	} else if timeout > 0 {
+		chain = append(chain, middlewares.Timeout(timeout))
+	}
+	if badBot {
+		chain = append(chain, middlewares.BadBotMiddleware)
+	}
+	chain = append(chain, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), types.ContextKeyRouteOptions, options)))
+		})
+	})
+	return chain.Handler(handler)
+}
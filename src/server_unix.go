@@ -240,6 +240,7 @@ func PidExists(pid int32) (bool, error) {
 func startChild(socket bool) {
 	log.Println("Initializing database...")
 	db.InitDB()
+	initLastKnownOptions()
 	log.Println("Initializing languages...")
 	initLanguages()
 	log.Println("Initializing pongo templates...")
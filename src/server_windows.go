@@ -29,6 +29,7 @@ func startServer() {
 func server(_ overseer.State) {
 	log.Println("Initializing database...")
 	db.InitDB()
+	initLastKnownOptions()
 	log.Println("Initializing languages...")
 	initLanguages()
 	log.Println("Initializing pongo templates...")
@@ -0,0 +1,143 @@
+package site
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/go-chi/chi/v5"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// cacheHeadersRule returns [cache_headers] rule for the rendered template. Rules are looked up by cache_headers
+// of [route_options], by route name (with _pagination suffix for paginated pages), then the default rule is used.
+// Error pages get only their own rules.
+func cacheHeadersRule(config *types.Config, name string, r *http.Request) (rule types.CacheHeadersRule, ok bool) {
+	if len(config.CacheHeaders) == 0 {
+		return
+	}
+	routeName := strings.ReplaceAll(name, "-", "_")
+	if strings.HasPrefix(name, "custom-") {
+		routeName = strings.TrimPrefix(name, "custom-")
+	}
+	var names []string
+	if options, ok := routeOptions(r); ok && options.CacheHeaders != "" {
+		names = append(names, options.CacheHeaders)
+	}
+	if chi.URLParam(r, "page") != "" || r.URL.Query().Get(config.Params.Page) != "" {
+		names = append(names, routeName+"_pagination")
+	}
+	names = append(names, routeName)
+	if !isErrorTemplate(name) {
+		names = append(names, "default")
+	}
+	for _, n := range names {
+		if rule, ok = config.CacheHeaders[n]; ok {
+			return
+		}
+	}
+	return
+}
+
+func cacheControl(rule types.CacheHeadersRule) string {
+	var parts []string
+	add := func(directive string, d *types.Duration) {
+		if d != nil {
+			parts = append(parts, directive+"="+strconv.FormatInt(int64(time.Duration(*d)/time.Second), 10))
+		}
+	}
+	if rule.Private {
+		parts = append(parts, "private")
+	} else {
+		parts = append(parts, "public")
+	}
+	add("max-age", rule.MaxAge)
+	if !rule.Private {
+		add("s-maxage", rule.SMaxAge)
+	}
+	add("stale-while-revalidate", rule.StaleWhileRevalidate)
+	add("stale-if-error", rule.StaleIfError)
+	return strings.Join(parts, ", ")
+}
+
+// surrogateKeys returns keys for selective CDN purge: host, template and ids of the page category, model, channel
+// or content item.
+func surrogateKeys(config *types.Config, name string, ctx pongo2.Context) []string {
+	host := config.Hostname
+	keys := []string{host, host + ":" + name}
+	add := func(kind string, id int64) {
+		if id > 0 {
+			keys = append(keys, host+":"+kind+"-"+strconv.FormatInt(id, 10))
+		}
+	}
+	switch v := ctx["category"].(type) {
+	case *types.CategoryResult:
+		add("category", int64(v.Id))
+	case types.CategoryResult:
+		add("category", int64(v.Id))
+	}
+	switch v := ctx["model"].(type) {
+	case *types.ModelResult:
+		add("model", int64(v.Id))
+	case types.ModelResult:
+		add("model", int64(v.Id))
+	}
+	switch v := ctx["channel"].(type) {
+	case *types.ChannelResult:
+		add("channel", int64(v.Id))
+	case types.ChannelResult:
+		add("channel", int64(v.Id))
+	}
+	switch v := ctx["content_item"].(type) {
+	case *types.ContentItemResult:
+		add("content", v.Id)
+	case types.ContentItemResult:
+		add("content", v.Id)
+	}
+	return keys
+}
+
+// setCacheHeaders sets Cache-Control, Vary and Surrogate-Key / Cache-Tag headers of the page by [cache_headers]
+// rules of the site. Headers already set by the handler (like no-cache for rotation) are kept.
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, config *types.Config, name string, ctx pongo2.Context) {
+	if internal.Config.General.Development {
+		return
+	}
+	rule, ok := cacheHeadersRule(config, name, r)
+	if !ok || w.Header().Get("Cache-Control") != "" {
+		return
+	}
+	if !rule.Private && personalized(w) {
+		rule.Private = true
+	}
+	w.Header().Set("Cache-Control", cacheControl(rule))
+	for _, v := range rule.Vary {
+		w.Header().Add("Vary", v)
+	}
+	if !rule.Private {
+		keys := surrogateKeys(config, name, ctx)
+		w.Header().Set("Surrogate-Key", strings.Join(keys, " "))
+		w.Header().Set("Cache-Tag", strings.Join(keys, ","))
+	}
+}
+
+// personalized checks if the page can't be shared by CDN: it sets cookies or depends on the country group
+// of the surfer, which can't be expressed with Vary header.
+func personalized(w http.ResponseWriter) bool {
+	return w.Header().Get("Set-Cookie") != "" || len(internal.CountryGroups) > 0
+}
+
+// isErrorTemplate checks if the template is error page, like 404 or 5xx.
+func isErrorTemplate(name string) bool {
+	if len(name) != 3 || name[0] != '4' && name[0] != '5' {
+		return false
+	}
+	if name[1:] == "xx" {
+		return true
+	}
+	return name[1] >= '0' && name[1] <= '9' && name[2] >= '0' && name[2] <= '9'
+}
@@ -0,0 +1,143 @@
+package site
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+func testDuration(d time.Duration) *types.Duration {
+	v := types.Duration(d)
+	return &v
+}
+
+// useTestConfig replaces global config with empty one for the test.
+func useTestConfig(t *testing.T) *internal.ConfigT {
+	config := internal.Config
+	internal.Config = &internal.ConfigT{}
+	t.Cleanup(func() { internal.Config = config })
+	return internal.Config
+}
+
+func TestSetCacheHeaders(t *testing.T) {
+	useTestConfig(t)
+	config := &types.Config{
+		Hostname: "example.com",
+		Params:   types.ConfigParams{Page: "page"},
+		CacheHeaders: map[string]types.CacheHeadersRule{
+			"default":             {MaxAge: testDuration(time.Minute), SMaxAge: testDuration(10 * time.Minute), Vary: []string{"Accept-Language"}},
+			"category_pagination": {MaxAge: testDuration(2 * time.Minute)},
+			"search":              {Private: true, MaxAge: testDuration(time.Minute), SMaxAge: testDuration(time.Hour)},
+			"no_cdn":              {Private: true},
+			"404":                 {MaxAge: testDuration(time.Second)},
+			"content_item":        {MaxAge: testDuration(5 * time.Minute), StaleIfError: testDuration(time.Hour)},
+			"custom_route":        {SMaxAge: testDuration(time.Hour)},
+		},
+	}
+	tests := []struct {
+		name          string
+		template      string
+		query         string
+		options       *types.RouteOptions
+		cookie        bool
+		countryGroups []types.CountryGroup
+		preset        string
+		want          string
+		wantKeys      string
+		wantVary      string
+	}{
+		{name: "default rule", template: "top-categories", want: "public, max-age=60, s-maxage=600",
+			wantKeys: "example.com example.com:top-categories", wantVary: "Accept-Language"},
+		{name: "route rule", template: "content-item", want: "public, max-age=300, stale-if-error=3600",
+			wantKeys: "example.com example.com:content-item"},
+		{name: "pagination rule", template: "category", query: "page=2", want: "public, max-age=120",
+			wantKeys: "example.com example.com:category"},
+		{name: "no pagination rule", template: "category", want: "public, max-age=60, s-maxage=600",
+			wantKeys: "example.com example.com:category", wantVary: "Accept-Language"},
+		{name: "custom route", template: "custom-custom_route", want: "public, s-maxage=3600",
+			wantKeys: "example.com example.com:custom-custom_route"},
+		{name: "private rule has no s-maxage and keys", template: "search", want: "private, max-age=60"},
+		{name: "rule of route options", template: "content-item", options: &types.RouteOptions{CacheHeaders: "no_cdn"},
+			want: "private"},
+		{name: "error page with own rule", template: "404", want: "public, max-age=1", wantKeys: "example.com example.com:404"},
+		{name: "error page without own rule", template: "500"},
+		{name: "cache-control set by handler", template: "content-item", preset: "no-cache", want: "no-cache"},
+		{name: "page with cookie is private", template: "top-categories", cookie: true, want: "private, max-age=60",
+			wantVary: "Accept-Language"},
+		{name: "country groups make page private", template: "content-item",
+			countryGroups: []types.CountryGroup{{Id: 1, Name: "tier1", Countries: []string{"US"}}},
+			want:          "private, max-age=300, stale-if-error=3600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			countryGroups := internal.CountryGroups
+			internal.CountryGroups = tt.countryGroups
+			defer func() { internal.CountryGroups = countryGroups }()
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			if tt.options != nil {
+				r = r.WithContext(context.WithValue(r.Context(), types.ContextKeyRouteOptions, *tt.options))
+			}
+			w := httptest.NewRecorder()
+			if tt.preset != "" {
+				w.Header().Set("Cache-Control", tt.preset)
+			}
+			if tt.cookie {
+				http.SetCookie(w, &http.Cookie{Name: "visited", Value: "1"})
+			}
+			setCacheHeaders(w, r, config, tt.template, pongo2.Context{})
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Fatalf("Cache-Control = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("Surrogate-Key"); got != tt.wantKeys {
+				t.Fatalf("Surrogate-Key = %q, want %q", got, tt.wantKeys)
+			}
+			if got := w.Header().Get("Vary"); got != tt.wantVary {
+				t.Fatalf("Vary = %q, want %q", got, tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestSetCacheHeadersDevelopment(t *testing.T) {
+	useTestConfig(t).General.Development = true
+	config := &types.Config{CacheHeaders: map[string]types.CacheHeadersRule{"default": {MaxAge: testDuration(time.Minute)}}}
+	w := httptest.NewRecorder()
+	setCacheHeaders(w, httptest.NewRequest(http.MethodGet, "/", nil), config, "index", pongo2.Context{})
+	if got := w.Header().Get("Cache-Control"); got != "" {
+		t.Fatalf("Cache-Control = %q in development mode", got)
+	}
+}
+
+func TestIsErrorTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "404", want: true},
+		{name: "500", want: true},
+		{name: "503", want: true},
+		{name: "4xx", want: true},
+		{name: "5xx", want: true},
+		{name: "200"},
+		{name: "3xx"},
+		{name: "40"},
+		{name: "4040"},
+		{name: "4x4"},
+		{name: "5-1"},
+		{name: "4+1"},
+		{name: "index"},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		if got := isErrorTemplate(tt.name); got != tt.want {
+			t.Errorf("isErrorTemplate(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package site
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/flosch/pongo2/v6"
+)
+
+// TemplateExists returns true if the site has template with the name, found the same way as on rendering.
+func TemplateExists(name, path string) bool {
+	matches, _ := filepath.Glob(filepath.Join(path, "templates", "*"))
+	for _, m := range matches {
+		if templateFileMatches(filepath.Base(m), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTemplates compiles every .twig template of the site with registered tags and filters.
+// Returns compilation errors by template path relative to the site path.
+// Templates of subdirectories can be language overrides, so their includes fall back to templates root as on rendering.
+func CheckTemplates(path string) map[string]error {
+	return checkTemplatesDir(path, TemplatesDir)
+}
+
+// CheckDraftTemplates compiles every .twig template of templates-draft directory of the site.
+func CheckDraftTemplates(path string) map[string]error {
+	return checkTemplatesDir(path, DraftTemplatesDir)
+}
+
+func checkTemplatesDir(path, dir string) map[string]error {
+	errs := make(map[string]error)
+	templatesPath := filepath.Join(path, dir)
+	sets := make(map[string]*pongo2.TemplateSet)
+	_ = filepath.WalkDir(templatesPath, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(path, p)
+		if err != nil {
+			errs[rel] = err
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(p) != ".twig" {
+			return nil
+		}
+		inTemplates, _ := filepath.Rel(templatesPath, p)
+		subDir, _, found := strings.Cut(filepath.ToSlash(inTemplates), "/")
+		if !found {
+			subDir = ""
+		}
+		set, ok := sets[subDir]
+		if !ok {
+			var loader pongo2.TemplateLoader = pongo2.DefaultLoader
+			if subDir != "" {
+				loader = newLayeredLoader(loader, filepath.Join(templatesPath, subDir), templatesPath)
+			}
+			set = pongo2.NewSet(filepath.Base(path)+":check:"+subDir, loader)
+			set.Options.LStripBlocks = true
+			set.Options.TrimBlocks = true
+			sets[subDir] = set
+		}
+		if _, err = set.FromFile(p); err != nil {
+			errs[rel] = err
+		}
+		return nil
+	})
+	return errs
+}
+
+// CheckExtensions compiles every extensions/*.js of the site.
+func CheckExtensions(path string) map[string]error {
+	errs := make(map[string]error)
+	matches, _ := filepath.Glob(filepath.Join(path, "extensions", "*.js"))
+	for _, m := range matches {
+		rel, _ := filepath.Rel(path, m)
+		source, err := os.ReadFile(m)
+		if err != nil {
+			errs[rel] = err
+			continue
+		}
+		if _, err = goja.Compile(rel, string(source), true); err != nil {
+			errs[rel] = err
+		}
+	}
+	return errs
+}
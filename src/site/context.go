@@ -125,6 +125,11 @@ func generateContext(name string, sitePath string, customContext pongo2.Context)
 		"static": func(filePaths ...string) string {
 			filePath := strings.Join(filePaths, "")
 			p := filepath.Join(sitePath, "public", filePath)
+			if config, ok := customContext["config"].(*types.Config); ok {
+				if themePath, found := ThemeStaticPath(sitePath, config, filePath); found {
+					p = themePath
+				}
+			}
 			if fileInfo, err := os.Stat(p); err == nil {
 				v := strconv.FormatInt(fileInfo.ModTime().Unix(), 10)
 				v = v[len(v)-5:]
@@ -0,0 +1,84 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// traceTemplate stores template, cache and surfer info to the debug trace of the request.
+func traceTemplate(config *types.Config, name, cacheKey string, cacheTtl time.Duration, nocache bool, customContext pongo2.Context) {
+	trace := config.Trace()
+	if trace == nil {
+		return
+	}
+	var language, countryGroup string
+	if lang, ok := customContext["lang"].(*types.Language); ok && lang != nil {
+		language = lang.Id
+	}
+	if group, ok := customContext["country_group"].(types.CountryGroup); ok {
+		countryGroup = group.Name
+	}
+	trace.SetPage(name, language, countryGroup)
+	switch {
+	case cacheTtl <= 0:
+		trace.SetCache(cacheKey, "disabled", 0)
+	case nocache:
+		trace.SetCache(cacheKey, "nocache", 0)
+	default:
+		status, ttl := db.CacheState(cacheKey)
+		trace.SetCache(cacheKey, status, ttl)
+	}
+}
+
+// insertDebugToolbar inserts debug toolbar to the end of html page in development mode.
+func insertDebugToolbar(parsed []byte, config *types.Config) []byte {
+	trace := config.Trace()
+	if trace == nil {
+		return parsed
+	}
+	pos := bytes.LastIndex(parsed, []byte("</body>"))
+	if pos < 0 {
+		return parsed
+	}
+	trace.Lock()
+	defer trace.Unlock()
+	var b strings.Builder
+	row := func(name, value string) {
+		b.WriteString(`<tr><td style="padding:1px 8px 1px 0;color:#9cf;white-space:nowrap">` + html.EscapeString(name) +
+			`</td><td style="padding:1px 0">` + html.EscapeString(value) + `</td></tr>`)
+	}
+	b.WriteString(`<div id="tt-debug-toolbar" style="position:fixed;right:0;bottom:0;z-index:2147483647;max-width:100%;max-height:60vh;overflow:auto;` +
+		`background:rgba(20,20,20,.92);color:#eee;font:12px/1.4 monospace;padding:6px 10px;text-align:left">`)
+	b.WriteString(`<details><summary style="cursor:pointer">` + html.EscapeString(fmt.Sprintf("%s | cache %s | render %s",
+		trace.Template, trace.CacheStatus, trace.RenderTime.Round(time.Microsecond))) + `</summary><table style="border-collapse:collapse">`)
+	row("template", trace.Template)
+	row("cache key", trace.CacheKey)
+	row("cache", trace.CacheStatus)
+	if trace.CacheTtl > 0 {
+		row("ttl left", trace.CacheTtl.Round(time.Second).String())
+	}
+	row("render", trace.RenderTime.Round(time.Microsecond).String())
+	row("dynamic", trace.DynamicTime.Round(time.Microsecond).String())
+	row("language", trace.Language)
+	row("country group", trace.CountryGroup)
+	for _, call := range trace.Calls {
+		value := call.Duration.Round(time.Microsecond).String() + " " + call.Name
+		if call.Error != "" {
+			value += " error: " + call.Error
+		}
+		row(call.Kind, value)
+	}
+	b.WriteString(`</table></details></div>`)
+	result := make([]byte, 0, len(parsed)+b.Len())
+	result = append(result, parsed[:pos]...)
+	result = append(result, b.String()...)
+	return append(result, parsed[pos:]...)
+}
@@ -0,0 +1,117 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+var gojaStackRegex = regexp.MustCompile(`at (?:(\S+) \()?[^\s()]+:(\d+):(\d+)`)
+
+// traceError stores template or extension error with its position to the debug trace of the request,
+// so the development error page can show it.
+func traceError(config *types.Config, kind, file string, err error, ctx pongo2.Context) {
+	trace := config.Trace()
+	if trace == nil || err == nil {
+		return
+	}
+	e := types.DebugError{Kind: kind, File: file, Message: err.Error()}
+	var pongoErr *pongo2.Error
+	var gojaException *goja.Exception
+	var gojaSyntaxErr *goja.CompilerSyntaxError
+	switch {
+	case errors.As(err, &pongoErr):
+		e.Sender, e.Line, e.Column = pongoErr.Sender, pongoErr.Line, pongoErr.Column
+		if pongoErr.OrigError != nil {
+			e.Message = pongoErr.OrigError.Error()
+		}
+		if pongoErr.Filename != "" {
+			e.File = pongoErr.Filename
+			if !filepath.IsAbs(e.File) {
+				e.File = filepath.Join(filepath.Dir(file), e.File)
+			}
+		}
+	case errors.As(err, &gojaException):
+		// goja doesn't export stack frames, so position is taken from the stack trace text
+		if m := gojaStackRegex.FindStringSubmatch(gojaException.String()); m != nil {
+			e.Sender = m[1]
+			e.Line, _ = strconv.Atoi(m[2])
+			e.Column, _ = strconv.Atoi(m[3])
+		}
+		if gojaException.Value() != nil {
+			e.Message = gojaException.Value().String()
+		}
+	case errors.As(err, &gojaSyntaxErr):
+		e.Message = gojaSyntaxErr.Message
+		if gojaSyntaxErr.File != nil {
+			pos := gojaSyntaxErr.File.Position(gojaSyntaxErr.Offset)
+			e.Line, e.Column = pos.Line, pos.Column
+		}
+	}
+	for k := range ctx {
+		e.ContextKeys = append(e.ContextKeys, k)
+	}
+	sort.Strings(e.ContextKeys)
+	trace.AddError(e)
+}
+
+// traceErrorResult returns the error for the page if an extension failed while it was rendered.
+func traceErrorResult(config *types.Config) error {
+	if e, ok := config.Trace().FirstError(); ok {
+		return errors.New(e.Kind + " error in " + filepath.Base(e.File) + ": " + e.Message)
+	}
+	return nil
+}
+
+// DevErrorPage renders the page with the first template or extension error of the request in development mode.
+func DevErrorPage(config *types.Config) ([]byte, bool) {
+	e, ok := config.Trace().FirstError()
+	if !ok {
+		return nil, false
+	}
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>` + html.EscapeString(e.Kind) + ` error</title></head>` +
+		`<body style="margin:0;padding:20px;background:#1e1e1e;color:#ddd;font:14px/1.5 monospace">`)
+	b.WriteString(`<h1 style="color:#f66;font-size:20px;margin:0 0 10px">` + html.EscapeString(e.Message) + `</h1>`)
+	location := e.File
+	if e.Line > 0 {
+		location += fmt.Sprintf(", line %d, column %d", e.Line, e.Column)
+	}
+	b.WriteString(`<div>` + html.EscapeString(location) + `</div>`)
+	if e.Sender != "" {
+		b.WriteString(`<div>sender: ` + html.EscapeString(e.Sender) + `</div>`)
+	}
+	if source, err := os.ReadFile(e.File); err == nil && e.Line > 0 {
+		lines := strings.Split(string(source), "\n")
+		b.WriteString(`<pre style="background:#111;padding:10px;overflow:auto">`)
+		for i := max(e.Line-6, 0); i < min(e.Line+5, len(lines)); i++ {
+			line := fmt.Sprintf("%5d  %s", i+1, lines[i])
+			if i+1 == e.Line {
+				b.WriteString(`<span style="background:#622;display:block">` + html.EscapeString(line) + `</span>`)
+				if e.Column > 0 {
+					b.WriteString(html.EscapeString(strings.Repeat(" ", 6+e.Column)) + "^\n")
+				}
+			} else {
+				b.WriteString(html.EscapeString(line) + "\n")
+			}
+		}
+		b.WriteString(`</pre>`)
+	}
+	if len(e.ContextKeys) > 0 {
+		b.WriteString(`<h2 style="font-size:16px">Context (` + strconv.Itoa(len(e.ContextKeys)) + ` keys)</h2><div>` +
+			html.EscapeString(strings.Join(e.ContextKeys, ", ")) + `</div>`)
+	}
+	b.WriteString(`</body></html>`)
+	return []byte(b.String()), true
+}
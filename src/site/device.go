@@ -0,0 +1,70 @@
+package site
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/mileusna/useragent"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+// Device classes of device_templates option. Template category.mobile.twig is used instead of category.twig for mobile.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+func isDeviceClass(s string) bool {
+	switch s {
+	case DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot:
+		return true
+	}
+	return false
+}
+
+// DetectDevice returns device class of the user agent if device_templates are enabled for the site, empty string otherwise.
+func DetectDevice(config *types.Config, userAgent string) string {
+	if !config.General.DeviceTemplates {
+		return ""
+	}
+	ua := useragent.Parse(userAgent)
+	switch {
+	case ua.Bot:
+		return DeviceBot
+	case ua.Tablet:
+		return DeviceTablet
+	case ua.Mobile:
+		return DeviceMobile
+	}
+	return DeviceDesktop
+}
+
+// templateFileMatches reports if the file in templates directory is the template with the name.
+// Device variants like category.mobile.twig are matched only by their full name.
+func templateFileMatches(base, name string) bool {
+	if base == name+".twig" {
+		return true
+	}
+	sp := strings.Split(base, ".")
+	return sp[0] == name && (len(sp) < 3 || !isDeviceClass(sp[1]))
+}
+
+// contextDevice returns device class set by the handler to the template context.
+func contextDevice(ctx pongo2.Context) string {
+	device, _ := ctx["device"].(string)
+	return device
+}
+
+// deviceCacheKey folds the device class into the page cache key. Caches in front of the site must vary by user agent
+// too, as the same url can have different html for different devices.
+func deviceCacheKey(w http.ResponseWriter, cacheKey, device string) string {
+	if device == "" {
+		return cacheKey
+	}
+	w.Header().Add("Vary", "User-Agent")
+	return cacheKey + ":" + device
+}
@@ -0,0 +1,147 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffMaxCells limits memory of the longest common subsequence table. Larger files are shown as fully replaced.
+const diffMaxCells = 4_000_000
+
+const diffContext = 3
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns line diff of two texts in unified format with 3 lines of context. Empty string means
+// the texts are equal.
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := splitLines(from), splitLines(to)
+	lines := diffLines(a, b)
+	var sb strings.Builder
+	sb.WriteString("--- " + fromName + "\n")
+	sb.WriteString("+++ " + toName + "\n")
+	// hunks are changed lines with context around them
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			// context between changes is kept in the same hunk if it's short
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next < len(lines) && next-end <= diffContext*2 {
+				end = next
+				continue
+			}
+			end = min(end+diffContext, len(lines))
+			break
+		}
+		aStart, bStart := 1, 1
+		for _, l := range lines[:start] {
+			if l.op != '+' {
+				aStart++
+			}
+			if l.op != '-' {
+				bStart++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount))
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns edit script of two line lists by the longest common subsequence.
+func diffLines(a, b []string) (lines []diffLine) {
+	// common prefix and suffix don't need the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > diffMaxCells {
+		for _, l := range ma {
+			lines = append(lines, diffLine{'-', l})
+		}
+		for _, l := range mb {
+			lines = append(lines, diffLine{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				lines = append(lines, diffLine{' ', ma[i]})
+				i++
+				j++
+			case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+				lines = append(lines, diffLine{'-', ma[i]})
+				i++
+			default:
+				lines = append(lines, diffLine{'+', mb[j]})
+				j++
+			}
+		}
+	}
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	return
+}
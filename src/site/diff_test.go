@@ -0,0 +1,73 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{name: "equal texts", from: "a\nb\n", to: "a\nb\n"},
+		{name: "changed line", from: "a\nb\nc\n", to: "a\nx\nc\n", want: "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{name: "added file", to: "a\nb\n", want: "@@ -1,0 +1,2 @@\n+a\n+b\n"},
+		{name: "removed file", from: "a\n", want: "@@ -1,1 +1,0 @@\n-a\n"},
+		{name: "missing final newline", from: "a\nb", to: "a\nb\nc", want: "@@ -1,2 +1,3 @@\n a\n b\n+c\n"},
+		{
+			name: "context is limited to 3 lines",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:   "1\n2\n3\n4\nx\n6\n7\n8\n9\n",
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n",
+		},
+		{
+			name: "close changes are in one hunk",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			to:   "x\n2\n3\n4\n5\n6\n7\ny\n",
+			want: "@@ -1,8 +1,8 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n",
+		},
+		{
+			name: "distant changes are in separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			to:   "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			want: "@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+		{
+			name: "moved line",
+			from: "a\nb\nc\n",
+			to:   "b\nc\na\n",
+			want: "@@ -1,3 +1,3 @@\n-a\n b\n c\n+a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("from", "to", tt.from, tt.to)
+			if tt.want != "" {
+				tt.want = "--- from\n+++ to\n" + tt.want
+			}
+			if got != tt.want {
+				t.Fatalf("diff:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesLargeFiles(t *testing.T) {
+	// files too large for the common subsequence table are shown as fully replaced between common prefix and suffix
+	a := []string{"head"}
+	b := []string{"head"}
+	for i := 0; i < 2100; i++ {
+		a = append(a, "a"+strings.Repeat("x", i%7))
+		b = append(b, "b"+strings.Repeat("x", i%7))
+	}
+	a, b = append(a, "tail"), append(b, "tail")
+	lines := diffLines(a, b)
+	if len(lines) != 2+2100*2 {
+		t.Fatalf("%d lines", len(lines))
+	}
+	if lines[0].op != ' ' || lines[1].op != '-' || lines[2101].op != '+' || lines[len(lines)-1].op != ' ' {
+		t.Fatal("unexpected edit script")
+	}
+}
@@ -0,0 +1,301 @@
+package site
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sersh.com/totaltube/frontend/db"
+)
+
+// Files of the site which versions are kept in the history: configs and live templates.
+var historyConfigFiles = []string{"config.toml", "config-translations.toml"}
+
+// historyFile returns the file path relative to the site with forward slashes if the file versions are kept.
+func historyFile(sitePath, file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		absSitePath, _ := filepath.Abs(sitePath)
+		rel, err := filepath.Rel(absSitePath, file)
+		if err != nil {
+			return "", false
+		}
+		file = rel
+	}
+	file = path.Clean(filepath.ToSlash(file))
+	if file == "." || file == ".." || strings.HasPrefix(file, "../") || strings.HasPrefix(file, "/") {
+		return "", false
+	}
+	for _, f := range historyConfigFiles {
+		if file == f {
+			return file, true
+		}
+	}
+	return file, strings.HasPrefix(file, TemplatesDir+"/")
+}
+
+// SnapshotFile saves current content of the site file to the history. Removed file is saved as deleted version,
+// changed directory is saved file by file.
+func SnapshotFile(sitePath, file string) {
+	rel, ok := historyFile(sitePath, file)
+	if !ok {
+		return
+	}
+	host := filepath.Base(sitePath)
+	p := filepath.Join(sitePath, filepath.FromSlash(rel))
+	info, err := os.Stat(p)
+	if err == nil && info.IsDir() {
+		SnapshotDir(sitePath, p)
+		return
+	}
+	var content []byte
+	deleted := os.IsNotExist(err)
+	if !deleted {
+		if content, err = os.ReadFile(p); err != nil {
+			log.Println("can't save version of", p, err)
+			return
+		}
+	}
+	if _, err = db.SaveFileVersion(host, rel, content, deleted); err != nil {
+		log.Println("can't save version of", p, err)
+	}
+	if deleted {
+		// removed directory removes all its files
+		versions, err := db.FileVersions(host, "")
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		for _, v := range latestVersions(versions) {
+			if !v.Deleted && strings.HasPrefix(v.File, rel+"/") {
+				if _, err = db.SaveFileVersion(host, v.File, nil, true); err != nil {
+					log.Println("can't save version of", v.File, err)
+				}
+			}
+		}
+	}
+}
+
+// SnapshotDir saves every file of the directory to the history.
+func SnapshotDir(sitePath, dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			SnapshotFile(sitePath, p)
+		}
+		return nil
+	})
+}
+
+// SnapshotSite saves configs and templates of the site to the history, so changes made while the server was
+// stopped get there too. Files removed since the last version are saved as deleted.
+func SnapshotSite(sitePath string) {
+	for _, f := range historyConfigFiles {
+		SnapshotFile(sitePath, f)
+	}
+	SnapshotDir(sitePath, filepath.Join(sitePath, TemplatesDir))
+	versions, err := db.FileVersions(filepath.Base(sitePath), "")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, v := range latestVersions(versions) {
+		if _, err := os.Stat(filepath.Join(sitePath, filepath.FromSlash(v.File))); os.IsNotExist(err) && !v.Deleted {
+			SnapshotFile(sitePath, v.File)
+		}
+	}
+}
+
+// latestVersions returns the newest version of every file, versions are sorted the newest first.
+func latestVersions(versions []db.FileVersion) (latest []db.FileVersion) {
+	seen := make(map[string]bool)
+	for _, v := range versions {
+		if !seen[v.File] {
+			seen[v.File] = true
+			latest = append(latest, v)
+		}
+	}
+	return
+}
+
+// History returns saved versions of the site file, or of all files of the site if file is empty, the newest first.
+func History(sitePath, file string) ([]db.FileVersion, error) {
+	if file == "" {
+		return db.FileVersions(filepath.Base(sitePath), "")
+	}
+	rel, ok := historyFile(sitePath, file)
+	if !ok {
+		return nil, errors.New("no history is kept for " + file)
+	}
+	return db.FileVersions(filepath.Base(sitePath), rel)
+}
+
+// parseVersionTime parses point in time of rollback and diff: RFC 3339 time, date with time or just date
+// in the local timezone.
+func parseVersionTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// findVersion returns the version of the file by hash prefix or by point in time.
+func findVersion(host, file, ref string) (db.FileVersion, error) {
+	if t, ok := parseVersionTime(ref); ok {
+		return db.FileVersionAt(host, file, t)
+	}
+	return db.FindFileVersion(host, file, ref)
+}
+
+func versionContent(v db.FileVersion) ([]byte, error) {
+	if v.Deleted {
+		return nil, nil
+	}
+	return db.VersionContent(v.Hash)
+}
+
+// HistoryDiff returns line diff of two versions of the site file. Versions are referenced by hash prefix or by
+// point in time. Empty to means the current file, empty from means the version before the newest one.
+func HistoryDiff(sitePath, file, from, to string) (string, error) {
+	rel, ok := historyFile(sitePath, file)
+	if !ok {
+		return "", errors.New("no history is kept for " + file)
+	}
+	host := filepath.Base(sitePath)
+	var fromVersion db.FileVersion
+	var fromContent, toContent []byte
+	var err error
+	toName := rel + " (current)"
+	if to == "" {
+		toContent, _ = os.ReadFile(filepath.Join(sitePath, filepath.FromSlash(rel)))
+	} else {
+		toVersion, err := findVersion(host, rel, to)
+		if err != nil {
+			return "", errors.Wrap(err, "version "+to+" of "+rel)
+		}
+		if toContent, err = versionContent(toVersion); err != nil {
+			return "", err
+		}
+		toName = versionName(toVersion)
+	}
+	if from == "" {
+		versions, err := db.FileVersions(host, rel)
+		if err != nil {
+			return "", err
+		}
+		if len(versions) < 2 {
+			return "", errors.Wrap(db.ErrVersionNotFound, "previous version of "+rel)
+		}
+		fromVersion = versions[1]
+	} else if fromVersion, err = findVersion(host, rel, from); err != nil {
+		return "", errors.Wrap(err, "version "+from+" of "+rel)
+	}
+	if fromContent, err = versionContent(fromVersion); err != nil {
+		return "", err
+	}
+	return unifiedDiff(versionName(fromVersion), toName, string(fromContent), string(toContent)), nil
+}
+
+func versionName(v db.FileVersion) string {
+	hash := v.Hash
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	if v.Deleted {
+		hash = "deleted"
+	}
+	return fmt.Sprintf("%s@%s (%s)", v.File, hash, v.Time.Local().Format("2006-01-02 15:04:05"))
+}
+
+// HistoryRollback restores the site file, or all files of the site if file is empty, to the version referenced
+// by hash prefix or point in time. Files which didn't exist at that time are removed. Whole site can be rolled back
+// only to the point in time. Returns restored files.
+func HistoryRollback(sitePath, file, to string) (restored []string, err error) {
+	host := filepath.Base(sitePath)
+	if file == "" {
+		t, ok := parseVersionTime(to)
+		if !ok {
+			return nil, errors.New("whole site can be rolled back only to the point in time, got " + to)
+		}
+		versions, err := db.FileVersions(host, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range latestVersions(versions) {
+			at, err := db.FileVersionAt(host, v.File, t)
+			if err == db.ErrVersionNotFound {
+				// file was created later
+				at = db.FileVersion{File: v.File, Deleted: true}
+			} else if err != nil {
+				return restored, err
+			}
+			changed, err := restoreVersion(sitePath, at)
+			if err != nil {
+				return restored, err
+			}
+			if changed {
+				restored = append(restored, v.File)
+			}
+		}
+		return restored, nil
+	}
+	rel, ok := historyFile(sitePath, file)
+	if !ok {
+		return nil, errors.New("no history is kept for " + file)
+	}
+	v, err := findVersion(host, rel, to)
+	if err == db.ErrVersionNotFound {
+		if _, isTime := parseVersionTime(to); isTime {
+			v, err = db.FileVersion{File: rel, Deleted: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "version "+to+" of "+rel)
+	}
+	changed, err := restoreVersion(sitePath, v)
+	if changed {
+		restored = append(restored, rel)
+	}
+	return
+}
+
+// restoreVersion writes the version content to the site file, or removes it for deleted version.
+// Restored file is saved to the history as the new version.
+func restoreVersion(sitePath string, v db.FileVersion) (changed bool, err error) {
+	p := filepath.Join(sitePath, filepath.FromSlash(v.File))
+	current, readErr := os.ReadFile(p)
+	if v.Deleted {
+		if os.IsNotExist(readErr) {
+			return false, nil
+		}
+		if err = os.Remove(p); err != nil {
+			return false, err
+		}
+	} else {
+		content, err := db.VersionContent(v.Hash)
+		if err != nil {
+			return false, err
+		}
+		if readErr == nil && string(current) == string(content) {
+			return false, nil
+		}
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return false, err
+		}
+		if err = os.WriteFile(p, content, 0644); err != nil {
+			return false, err
+		}
+	}
+	SnapshotFile(sitePath, v.File)
+	return true, nil
+}
@@ -0,0 +1,233 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sersh.com/totaltube/frontend/db"
+)
+
+var testDBOnce sync.Once
+
+// useTestDB opens in-memory database shared by the tests of the package.
+func useTestDB(t *testing.T) {
+	var err error
+	testDBOnce.Do(func() { err = db.InitMemoryDB() })
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSite creates the site directory with the files and saves them to the history. Host is unique,
+// as the database is shared.
+func testSite(t *testing.T, files map[string]string) string {
+	host := strings.ToLower(t.Name()) + strconv.FormatInt(time.Now().UnixNano(), 36) + ".com"
+	sitePath := filepath.Join(t.TempDir(), host)
+	for name, content := range files {
+		writeTestFile(t, sitePath, name, content)
+	}
+	SnapshotSite(sitePath)
+	return sitePath
+}
+
+func writeTestFile(t *testing.T, sitePath, name, content string) {
+	p := filepath.Join(sitePath, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// siteFiles returns history files of the site with their contents.
+func siteFiles(t *testing.T, sitePath string) map[string]string {
+	files := make(map[string]string)
+	for _, name := range []string{"config.toml", "templates/index.twig", "templates/new.twig"} {
+		if content, err := os.ReadFile(filepath.Join(sitePath, filepath.FromSlash(name))); err == nil {
+			files[name] = string(content)
+		} else if !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+// versionTime returns the time between the versions, so versions saved before it are found by it.
+func versionTime() string {
+	time.Sleep(time.Millisecond)
+	defer time.Sleep(time.Millisecond)
+	return time.Now().Format(time.RFC3339Nano)
+}
+
+func TestHistoryFile(t *testing.T) {
+	sitePath := filepath.Join("sites", "example.com")
+	abs, _ := filepath.Abs(sitePath)
+	tests := []struct {
+		file string
+		want string
+		ok   bool
+	}{
+		{file: "config.toml", want: "config.toml", ok: true},
+		{file: "config-translations.toml", want: "config-translations.toml", ok: true},
+		{file: "templates/index.twig", want: "templates/index.twig", ok: true},
+		{file: "templates/../config.toml", want: "config.toml", ok: true},
+		{file: filepath.Join(abs, "templates", "index.twig"), want: "templates/index.twig", ok: true},
+		{file: "public/app.js", want: "public/app.js"},
+		{file: "../other.com/config.toml"},
+		{file: "."},
+		{file: filepath.Join(filepath.Dir(abs), "other.com", "config.toml")},
+	}
+	for _, tt := range tests {
+		got, ok := historyFile(sitePath, tt.file)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("historyFile(%q) = %q, %v, want %q, %v", tt.file, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseVersionTime(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want time.Time
+		ok   bool
+	}{
+		{ref: "2024-05-01T10:20:30Z", want: time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC), ok: true},
+		{ref: "2024-05-01 10:20:30", want: time.Date(2024, 5, 1, 10, 20, 30, 0, time.Local), ok: true},
+		{ref: "2024-05-01 10:20", want: time.Date(2024, 5, 1, 10, 20, 0, 0, time.Local), ok: true},
+		{ref: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), ok: true},
+		{ref: "3f2a9c"},
+		{ref: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersionTime(tt.ref)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseVersionTime(%q) = %v, %v, want %v, %v", tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	useTestDB(t)
+	sitePath := testSite(t, map[string]string{"config.toml": "a = 1\nb = 2\n"})
+	first := versionTime()
+	writeTestFile(t, sitePath, "config.toml", "a = 1\nb = 3\n")
+	SnapshotFile(sitePath, "config.toml")
+	versions, err := History(sitePath, "config.toml")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("%d versions, %v", len(versions), err)
+	}
+	// current file differs from the newest version
+	writeTestFile(t, sitePath, "config.toml", "a = 1\nb = 4\n")
+	tests := []struct {
+		name     string
+		file     string
+		from, to string
+		want     string
+		err      string
+	}{
+		{name: "previous version to current file", file: "config.toml", want: "-b = 2\n+b = 4\n"},
+		{name: "by hash", file: "config.toml", from: versions[1].Hash[:8], to: versions[0].Hash[:8], want: "-b = 2\n+b = 3\n"},
+		{name: "by time", file: "config.toml", from: first, want: "-b = 2\n+b = 4\n"},
+		{name: "same version", file: "config.toml", from: versions[0].Hash, to: versions[0].Hash},
+		{name: "unknown version", file: "config.toml", from: "ffffffff", err: "version ffffffff of config.toml"},
+		{name: "no previous version", file: "templates/index.twig", err: "previous version of templates/index.twig"},
+		{name: "file without history", file: "public/app.js", err: "no history is kept for public/app.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := HistoryDiff(sitePath, tt.file, tt.from, tt.to)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" && diff != "" || !strings.Contains(diff, tt.want) {
+				t.Fatalf("diff:\n%s\nwant:\n%s", diff, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryRollback(t *testing.T) {
+	useTestDB(t)
+	sitePath := testSite(t, map[string]string{"config.toml": "v1", "templates/index.twig": "index v1"})
+	first := versionTime()
+	writeTestFile(t, sitePath, "config.toml", "v2")
+	writeTestFile(t, sitePath, "templates/new.twig", "new")
+	if err := os.Remove(filepath.Join(sitePath, "templates", "index.twig")); err != nil {
+		t.Fatal(err)
+	}
+	SnapshotSite(sitePath)
+	second := versionTime()
+	versions, err := History(sitePath, "config.toml")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("%d versions, %v", len(versions), err)
+	}
+	v1, v2 := versions[1].Hash[:8], versions[0].Hash[:8]
+	// steps are applied one after another
+	tests := []struct {
+		name     string
+		file, to string
+		restored []string
+		want     map[string]string
+		err      string
+	}{
+		{name: "file by hash", file: "config.toml", to: v1, restored: []string{"config.toml"},
+			want: map[string]string{"config.toml": "v1", "templates/new.twig": "new"}},
+		{name: "unchanged file", file: "config.toml", to: v1,
+			want: map[string]string{"config.toml": "v1", "templates/new.twig": "new"}},
+		{name: "site to point in time", to: first, restored: []string{"templates/index.twig", "templates/new.twig"},
+			want: map[string]string{"config.toml": "v1", "templates/index.twig": "index v1"}},
+		{name: "file created later is removed", file: "templates/new.twig", to: "2000-01-01",
+			want: map[string]string{"config.toml": "v1", "templates/index.twig": "index v1"}},
+		{name: "site forward", to: second, restored: []string{"config.toml", "templates/index.twig", "templates/new.twig"},
+			want: map[string]string{"config.toml": "v2", "templates/new.twig": "new"}},
+		{name: "deleted file by time", file: "templates/index.twig", to: first, restored: []string{"templates/index.twig"},
+			want: map[string]string{"config.toml": "v2", "templates/index.twig": "index v1", "templates/new.twig": "new"}},
+		{name: "site only by time", to: v2, err: "only to the point in time",
+			want: map[string]string{"config.toml": "v2", "templates/index.twig": "index v1", "templates/new.twig": "new"}},
+		{name: "unknown hash", file: "config.toml", to: "ffffffff", err: "version ffffffff of config.toml",
+			want: map[string]string{"config.toml": "v2", "templates/index.twig": "index v1", "templates/new.twig": "new"}},
+		{name: "file without history", file: "public/app.js", to: first, err: "no history is kept",
+			want: map[string]string{"config.toml": "v2", "templates/index.twig": "index v1", "templates/new.twig": "new"}},
+	}
+	for _, tt := range tests {
+		restored, err := HistoryRollback(sitePath, tt.file, tt.to)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		sort.Strings(restored)
+		if strings.Join(restored, ",") != strings.Join(tt.restored, ",") {
+			t.Fatalf("%s: restored %v, want %v", tt.name, restored, tt.restored)
+		}
+		files := siteFiles(t, sitePath)
+		if len(files) != len(tt.want) {
+			t.Fatalf("%s: files %v, want %v", tt.name, files, tt.want)
+		}
+		for name, content := range tt.want {
+			if files[name] != content {
+				t.Fatalf("%s: %s = %q, want %q", tt.name, name, files[name], content)
+			}
+		}
+	}
+	// rollback is saved to the history, so it can be undone
+	versions, err = History(sitePath, "config.toml")
+	if err != nil || len(versions) != 4 {
+		t.Fatalf("%d versions of config.toml after rollbacks, %v", len(versions), err)
+	}
+}
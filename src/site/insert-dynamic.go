@@ -1,11 +1,14 @@
 package site
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"html"
 	"log"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/flosch/pongo2/v6"
 	"sersh.com/totaltube/frontend/helpers"
@@ -13,78 +16,202 @@ import (
 
 var replaceDynamicRegex = regexp.MustCompile(`<data class=["']?_dynamic["']? value=["']?(.*?)["']?/?>(.*?)</data>`)
 
-func InsertDynamic(src []byte, path string, userCtx pongo2.Context) (result []byte) {
-	result = replaceDynamicRegex.ReplaceAllFunc(src, func(match []byte) (result []byte) {
-		var err error
-		matches := replaceDynamicRegex.FindSubmatch(match)
-		expression := html.UnescapeString(string(matches[1]))
-		if expression == "short" && string(matches[2]) != "" {
-			var expressionBytes []byte
-			expressionBytes, err = helpers.FromBase64(string(matches[2]))
+// Rendered page prepared by PrepareDynamic starts with this header followed by dynamic slots and html
+// without dynamic markers, so inserting dynamic blocks on request is just a splice.
+const dynamicArtifactMagic = "\x00ttdyn2\n"
+
+type dynamicKind byte
+
+const (
+	dynamicExpression dynamicKind = iota + 1
+	dynamicInclude
+	dynamicInner
+)
+
+type dynamicSlot struct {
+	offset int // position in html without markers
+	kind   dynamicKind
+	code   string
+}
+
+var dynamicTemplates sync.Map // md5 of kind + code -> *pongo2.Template
+
+// findDynamicSlots removes dynamic markers from rendered html and returns their positions.
+func findDynamicSlots(src []byte) (body []byte, slots []dynamicSlot) {
+	indexes := replaceDynamicRegex.FindAllSubmatchIndex(src, -1)
+	if len(indexes) == 0 {
+		return src, nil
+	}
+	body = make([]byte, 0, len(src))
+	last := 0
+	for _, m := range indexes {
+		body = append(body, src[last:m[0]]...)
+		last = m[1]
+		expression := html.UnescapeString(string(src[m[2]:m[3]]))
+		content := string(src[m[4]:m[5]])
+		if expression == "short" && content != "" {
+			expressionBytes, err := helpers.FromBase64(content)
 			if err != nil {
-				log.Println("Error rendering dynamic expression [ " + string(matches[2]) + " ]: " + err.Error())
-				return []byte("")
+				log.Println("Error rendering dynamic expression [ " + content + " ]: " + err.Error())
+				continue
 			}
 			expression = string(expressionBytes)
 		}
+		slot := dynamicSlot{offset: len(body)}
 		if strings.HasPrefix(expression, "include ") {
-			var tpl *pongo2.Template
-			tpl, err = pongo2.FromString("{{" + strings.TrimPrefix(expression, "include ") + "}}")
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + expression + " ]: " + err.Error() + " " + string(matches[1]) + " - " + expression)
-			}
-			var templateName string
-			templateName, err = tpl.Execute(userCtx)
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + expression + " ]: " + err.Error())
-			}
-			sp := strings.Split(templateName, ".")
-			if len(sp) > 1 && sp[len(sp)-1] == "twig" {
-				sp = sp[0 : len(sp)-1]
-			}
-			tpl, err = GetTemplate(strings.Join(sp, "."), path)
-			if err != nil {
-				if err == ErrTemplateNotFound {
-					err = errors.New("wrong template name")
-				}
-				return []byte("Error rendering dynamic expression [ " + expression + " ]: " + err.Error())
-			}
-			result, err = tpl.ExecuteBytes(userCtx)
+			slot.kind, slot.code = dynamicInclude, strings.TrimPrefix(expression, "include ")
+		} else if expression != "" && expression != "inner" {
+			slot.kind, slot.code = dynamicExpression, expression
+		} else if content != "" {
+			templateCode, err := helpers.FromBase64(content)
 			if err != nil {
-				log.Println(err)
+				log.Println("Error rendering dynamic expression [ " + content + " ]: " + err.Error())
+				continue
 			}
-			return result
+			slot.kind, slot.code = dynamicInner, string(templateCode)
+		} else {
+			continue
 		}
-		if expression != "" && expression != "inner" {
-			var tpl *pongo2.Template
-			tpl, err = pongo2.FromString("{{" + expression + "}}")
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + expression + " ]: " + err.Error())
-			}
-			result, err = tpl.ExecuteBytes(userCtx)
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + expression + " ]: " + err.Error())
-			}
-			return
+		slots = append(slots, slot)
+	}
+	body = append(body, src[last:]...)
+	return
+}
+
+// PrepareDynamic converts rendered html with dynamic markers to the artifact stored in cache.
+func PrepareDynamic(src []byte) []byte {
+	if bytes.HasPrefix(src, []byte(dynamicArtifactMagic)) {
+		return src
+	}
+	body, slots := findDynamicSlots(src)
+	size := len(dynamicArtifactMagic) + binary.MaxVarintLen64 + len(body)
+	for _, slot := range slots {
+		size += binary.MaxVarintLen64*2 + 1 + len(slot.code)
+	}
+	artifact := make([]byte, 0, size)
+	artifact = append(artifact, dynamicArtifactMagic...)
+	artifact = binary.AppendUvarint(artifact, uint64(len(slots)))
+	for _, slot := range slots {
+		artifact = binary.AppendUvarint(artifact, uint64(slot.offset))
+		artifact = append(artifact, byte(slot.kind))
+		artifact = binary.AppendUvarint(artifact, uint64(len(slot.code)))
+		artifact = append(artifact, slot.code...)
+	}
+	return append(artifact, body...)
+}
+
+// decodeDynamic reads the artifact made by PrepareDynamic.
+func decodeDynamic(src []byte) (body []byte, slots []dynamicSlot, ok bool) {
+	if !bytes.HasPrefix(src, []byte(dynamicArtifactMagic)) {
+		return nil, nil, false
+	}
+	rest := src[len(dynamicArtifactMagic):]
+	count, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return nil, nil, false
+	}
+	rest = rest[n:]
+	slots = make([]dynamicSlot, 0, count)
+	for i := uint64(0); i < count; i++ {
+		offset, n := binary.Uvarint(rest)
+		if n <= 0 || len(rest) < n+1 {
+			return nil, nil, false
 		}
-		innerExpression := matches[2]
-		if string(innerExpression) != "" {
-			templateCode, err := helpers.FromBase64(string(innerExpression))
-			if err != nil {
-				log.Println("Error rendering dynamic expression [ " + string(innerExpression) + " ]: " + err.Error())
-				return []byte("")
-			}
-			var tpl *pongo2.Template
-			tpl, err = pongo2.FromBytes(templateCode)
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + string(templateCode) + " ]: " + err.Error())
-			}
-			result, err = tpl.ExecuteBytes(userCtx)
-			if err != nil {
-				return []byte("Error rendering dynamic expression [ " + string(templateCode) + " ]: " + err.Error())
+		kind := dynamicKind(rest[n])
+		rest = rest[n+1:]
+		codeLen, n := binary.Uvarint(rest)
+		if n <= 0 || uint64(len(rest)-n) < codeLen {
+			return nil, nil, false
+		}
+		code := string(rest[n : n+int(codeLen)])
+		rest = rest[n+int(codeLen):]
+		slots = append(slots, dynamicSlot{offset: int(offset), kind: kind, code: code})
+	}
+	for _, slot := range slots {
+		if slot.offset > len(rest) {
+			return nil, nil, false
+		}
+	}
+	return rest, slots, true
+}
+
+// dynamicTemplate returns compiled template of the slot, compiling it only once. Fragments with errors are not
+// kept, they are compiled again on the next render.
+func dynamicTemplate(slot dynamicSlot) (*pongo2.Template, error) {
+	key := helpers.Md5Hash(string(rune(slot.kind)) + slot.code)
+	if tpl, ok := dynamicTemplates.Load(key); ok {
+		return tpl.(*pongo2.Template), nil
+	}
+	source := slot.code
+	if slot.kind != dynamicInner {
+		source = "{{" + slot.code + "}}"
+	}
+	tpl, err := pongo2.FromString(source)
+	if err != nil {
+		return nil, err
+	}
+	dynamicTemplates.Store(key, tpl)
+	return tpl, nil
+}
+
+func renderDynamicSlot(slot dynamicSlot, path string, userCtx pongo2.Context) []byte {
+	tpl, err := dynamicTemplate(slot)
+	switch slot.kind {
+	case dynamicInclude:
+		if err != nil {
+			return []byte("Error rendering dynamic expression [ include " + slot.code + " ]: " + err.Error())
+		}
+		var templateName string
+		templateName, err = tpl.Execute(userCtx)
+		if err != nil {
+			return []byte("Error rendering dynamic expression [ include " + slot.code + " ]: " + err.Error())
+		}
+		sp := strings.Split(templateName, ".")
+		if len(sp) > 1 && sp[len(sp)-1] == "twig" {
+			sp = sp[0 : len(sp)-1]
+		}
+		tpl, err = getContextTemplate(strings.Join(sp, "."), path, userCtx)
+		if err != nil {
+			if err == ErrTemplateNotFound {
+				err = errors.New("wrong template name")
 			}
+			return []byte("Error rendering dynamic expression [ include " + slot.code + " ]: " + err.Error())
+		}
+		result, err := tpl.ExecuteBytes(userCtx)
+		if err != nil {
+			log.Println(err)
 		}
 		return result
-	})
-	return
+	default:
+		if err != nil {
+			return []byte("Error rendering dynamic expression [ " + slot.code + " ]: " + err.Error())
+		}
+		result, err := tpl.ExecuteBytes(userCtx)
+		if err != nil {
+			return []byte("Error rendering dynamic expression [ " + slot.code + " ]: " + err.Error())
+		}
+		return result
+	}
+}
+
+// InsertDynamic renders dynamic blocks of the page for the current request. src is either the artifact
+// made by PrepareDynamic or rendered html with dynamic markers (old cache entries).
+func InsertDynamic(src []byte, path string, userCtx pongo2.Context) []byte {
+	body, slots, ok := decodeDynamic(src)
+	if !ok {
+		body, slots = findDynamicSlots(src)
+	}
+	if len(slots) == 0 {
+		return body
+	}
+	var out bytes.Buffer
+	out.Grow(len(body) + len(slots)*256)
+	last := 0
+	for _, slot := range slots {
+		out.Write(body[last:slot.offset])
+		out.Write(renderDynamicSlot(slot, path, userCtx))
+		last = slot.offset
+	}
+	out.Write(body[last:])
+	return out.Bytes()
 }
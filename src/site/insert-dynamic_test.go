@@ -0,0 +1,99 @@
+package site
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/helpers"
+)
+
+func dynamicTestPage(blocks int) []byte {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	inner := base64.StdEncoding.EncodeToString([]byte(`{% if user %}<b>{{ user }}</b>{% endif %}`))
+	for i := 0; i < blocks; i++ {
+		b.WriteString(`<div class="thumb"><a href="/video/` + strconv.Itoa(i) + `">Some video title ` + strconv.Itoa(i) + `</a></div>`)
+		if i%2 == 0 {
+			b.WriteString(`<data class="_dynamic" value="user|upper"></data>`)
+		} else {
+			b.WriteString(`<data class="_dynamic" value="inner">` + inner + `</data>`)
+		}
+	}
+	b.WriteString("</body></html>")
+	return []byte(b.String())
+}
+
+func TestInsertDynamicPrepared(t *testing.T) {
+	page := dynamicTestPage(10)
+	ctx := pongo2.Context{"user": "john"}
+	legacy := InsertDynamic(page, "", ctx)
+	prepared := InsertDynamic(PrepareDynamic(page), "", ctx)
+	if !bytes.Equal(legacy, prepared) {
+		t.Fatalf("prepared output differs:\n%s\n%s", legacy, prepared)
+	}
+	if bytes.Contains(prepared, []byte("_dynamic")) || !bytes.Contains(prepared, []byte("JOHN")) || !bytes.Contains(prepared, []byte("<b>john</b>")) {
+		t.Fatalf("dynamic blocks are not rendered: %s", prepared)
+	}
+}
+
+func BenchmarkInsertDynamicUnprepared(b *testing.B) {
+	page := dynamicTestPage(200)
+	ctx := pongo2.Context{"user": "john"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InsertDynamic(page, "", ctx)
+	}
+}
+
+func BenchmarkInsertDynamicPrepared(b *testing.B) {
+	page := PrepareDynamic(dynamicTestPage(200))
+	ctx := pongo2.Context{"user": "john"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InsertDynamic(page, "", ctx)
+	}
+}
+
+func TestDynamicTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		slot   dynamicSlot
+		output string
+		err    bool
+	}{
+		{name: "expression", slot: dynamicSlot{kind: dynamicExpression, code: "user|upper"}, output: "JOHN"},
+		{name: "inner template", slot: dynamicSlot{kind: dynamicInner, code: "<b>{{ user }}</b>"}, output: "<b>john</b>"},
+		{name: "broken expression", slot: dynamicSlot{kind: dynamicExpression, code: "user|"}, err: true},
+		{name: "broken inner template", slot: dynamicSlot{kind: dynamicInner, code: "{% if user %}"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := helpers.Md5Hash(string(rune(tt.slot.kind)) + tt.slot.code)
+			defer dynamicTemplates.Delete(key)
+			tpl, err := dynamicTemplate(tt.slot)
+			if _, stored := dynamicTemplates.Load(key); stored == tt.err {
+				t.Fatalf("stored = %v with error %v", stored, err)
+			}
+			if tt.err {
+				if err == nil {
+					t.Fatal("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again, _ := dynamicTemplate(tt.slot); again != tpl {
+				t.Fatal("template is compiled twice")
+			}
+			if out, _ := tpl.Execute(pongo2.Context{"user": "john"}); out != tt.output {
+				t.Fatalf("output = %q, want %q", out, tt.output)
+			}
+		})
+	}
+}
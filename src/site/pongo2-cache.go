@@ -0,0 +1,123 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// cache tag stores its rendered body in the cache, so expensive parts of the page are cached independently of it:
+// {% cache "sidebar" ttl="10m" vary=page %}...{% endcache %}
+
+const fragmentCachePrefix = "fragment:"
+
+type tagCacheNode struct {
+	key     pongo2.IEvaluator
+	ttl     pongo2.IEvaluator
+	vary    []pongo2.IEvaluator
+	wrapper *pongo2.NodeWrapper
+}
+
+func (node *tagCacheNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	key, err := node.key.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	ttl := time.Minute * 10
+	if node.ttl != nil {
+		t, err := node.ttl.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		if t.IsInteger() {
+			ttl = time.Second * time.Duration(t.Integer())
+		} else if d := types.ParseHumanDuration(t.String()); d > 0 {
+			ttl = d
+		}
+	}
+	if preview, _ := ctx.Public["preview"].(bool); preview {
+		// fragments of draft templates must not get to the cache
+		return node.wrapper.Execute(ctx, writer)
+	}
+	varyParts := make([]string, 0, len(node.vary)+1)
+	varyParts = append(varyParts, key.String())
+	for _, v := range node.vary {
+		value, err := v.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		varyParts = append(varyParts, fmt.Sprintf("%v", value.Interface()))
+	}
+	host, _ := ctx.Public["host"].(string)
+	langId := ""
+	if lang, ok := ctx.Public["lang"].(*types.Language); ok && lang != nil {
+		langId = lang.Id
+	}
+	if device, _ := ctx.Public["device"].(string); device != "" {
+		langId += ":" + device
+	}
+	nocache, _ := ctx.Public["nocache"].(bool)
+	cacheKey := fragmentCachePrefix + host + ":" + langId + ":" + helpers.Md5Hash(strings.Join(varyParts, "|"))
+	var renderErr *pongo2.Error
+	cached, e := db.GetCachedTimeout(cacheKey, ttl, ttl/2, func() ([]byte, error) {
+		var buf bytes.Buffer
+		if renderErr = node.wrapper.Execute(ctx, &buf); renderErr != nil {
+			return nil, renderErr
+		}
+		return buf.Bytes(), nil
+	}, nocache)
+	if renderErr != nil {
+		return renderErr
+	}
+	if e != nil {
+		log.Println(e, cacheKey)
+		return &pongo2.Error{Sender: "tag:cache", OrigError: e}
+	}
+	if _, e = writer.Write(cached); e != nil {
+		return &pongo2.Error{Sender: "tag:cache", OrigError: e}
+	}
+	return nil
+}
+
+func pongo2Cache(doc *pongo2.Parser, _ *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	node := &tagCacheNode{}
+	var err *pongo2.Error
+	if node.key, err = arguments.ParseExpression(); err != nil {
+		return nil, err
+	}
+	for arguments.Remaining() > 0 {
+		// named params can be separated by commas or spaces
+		arguments.Match(pongo2.TokenSymbol, ",")
+		idToken := arguments.MatchType(pongo2.TokenIdentifier)
+		if idToken == nil {
+			return nil, arguments.Error("Identifier expected", arguments.Current())
+		}
+		if equalToken := arguments.Match(pongo2.TokenSymbol, "="); equalToken == nil {
+			return nil, arguments.Error("= expected", idToken)
+		}
+		expression, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		switch idToken.Val {
+		case "ttl":
+			node.ttl = expression
+		case "vary":
+			node.vary = append(node.vary, expression)
+		default:
+			return nil, arguments.Error("Unknown param "+idToken.Val, idToken)
+		}
+	}
+	if node.wrapper, _, err = doc.WrapUntilTag("endcache"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
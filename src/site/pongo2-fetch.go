@@ -35,7 +35,7 @@ type tagFetchNode struct {
 
 var headerRegex = regexp.MustCompile(`^\s*([^:]+)\s*:\s*(.*?)\s*$`)
 
-func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) (fetchErr *pongo2.Error) {
 	fetchContext := pongo2.NewChildExecutionContext(ctx)
 	var cacheTimeout time.Duration
 	if node.cache != nil {
@@ -48,6 +48,16 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 	host, _ := ctx.Public["host"].(string)
 	nocache, _ := ctx.Public["nocache"].(bool)
 	config := ctx.Public["config"].(*types.Config)
+	if trace := config.Trace(); trace != nil {
+		started := time.Now()
+		defer func() {
+			var err error
+			if fetchErr != nil {
+				err = fetchErr
+			}
+			trace.AddCall("fetch", node.what, started, err)
+		}()
+	}
 	if strings.HasPrefix(node.what, "http://") || strings.HasPrefix(node.what, "https://") {
 		// Fetching information from user address
 		// First let's check if we have cache
@@ -238,6 +248,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 		minSearches = cv.Integer()
 	}
 	group, _ := ctx.Public["country_group"].(types.CountryGroup)
+	batch := fetchBatch(ctx, config)
 	cacheKey := ""
 	if cacheTimeout > 0 {
 		cacheKey = "in:fetch:" + host + ":" + helpers.Md5Hash(fmt.Sprintf("%s|%d|%d|%v|%s|%s|%v|%d|%d", node.what, amount, page, sort,
@@ -343,7 +354,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				searchQuery, lang, cacheTimeout, categoryId, categorySlug, channelId, channelSlug,
 				modelId, modelSlug, timeframe, tag, durationGte, durationLt))
 			cached, err := db.GetCachedTimeout(cacheKey, cacheTimeout, cacheTimeout/2, func() ([]byte, error) {
-				_, rawResponse, err := api.Content(config, api.ContentParams{
+				return batch.Content(api.ContentParams{
 					Ip:           net.ParseIP(ip),
 					Lang:         lang,
 					Page:         int64(page),
@@ -362,8 +373,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 					SearchQuery:  searchQuery,
 					UserAgent:    userAgent,
 					GroupId:      group.Id,
-				})
-				return rawResponse, err
+				}).RememberCached(cacheKey).Raw()
 			}, nocache)
 			if err != nil {
 				log.Println(err)
@@ -377,7 +387,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				fetchContext.Private["fetched_content"] = results
 			}
 		} else {
-			results, _, err := api.Content(config, api.ContentParams{
+			result, err := batch.Content(api.ContentParams{
 				Ip:           net.ParseIP(ip),
 				Lang:         lang,
 				Page:         int64(page),
@@ -396,12 +406,12 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				SearchQuery:  searchQuery,
 				UserAgent:    userAgent,
 				GroupId:      group.Id,
-			})
+			}).Remember().Result()
 			if err != nil {
 				log.Println(err)
 				return &pongo2.Error{Sender: "tag:fetch", OrigError: err}
 			}
-			fetchContext.Private["fetched_content"] = results
+			fetchContext.Private["fetched_content"] = result
 		}
 	case "categories":
 		if sort != api.SortTitle && sort != api.SortTotal && sort != api.SortPopular {
@@ -412,8 +422,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 		}
 		if cacheTimeout > 0 {
 			cached, err := db.GetCachedTimeout(cacheKey, cacheTimeout, cacheTimeout, func() ([]byte, error) {
-				_, rawResponse, err := api.CategoriesList(config, lang, int64(page), sort, int64(amount), group.Id)
-				return rawResponse, err
+				return batch.CategoriesList(lang, int64(page), sort, int64(amount), group.Id).RememberCached(cacheKey).Raw()
 			}, nocache)
 			if err != nil {
 				log.Println(err)
@@ -427,7 +436,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				fetchContext.Private["categories"] = results
 			}
 		} else {
-			results, _, err := api.CategoriesList(config, lang, int64(page), sort, int64(amount), group.Id)
+			results, err := batch.CategoriesList(lang, int64(page), sort, int64(amount), group.Id).Remember().Result()
 			if err != nil {
 				log.Println(err)
 				return &pongo2.Error{Sender: "tag:fetch", OrigError: err}
@@ -443,8 +452,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 		}
 		if cacheTimeout > 0 {
 			cached, err := db.GetCachedTimeout(cacheKey, cacheTimeout, cacheTimeout, func() ([]byte, error) {
-				_, rawResponse, err := api.ModelsList(config, lang, int64(page), sort, int64(amount), searchQuery, group.Id)
-				return rawResponse, err
+				return batch.ModelsList(lang, int64(page), sort, int64(amount), searchQuery, group.Id).RememberCached(cacheKey).Raw()
 			}, nocache)
 			if err != nil {
 				log.Println(err)
@@ -458,7 +466,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				fetchContext.Private["models"] = results
 			}
 		} else {
-			results, _, err := api.ModelsList(config, lang, int64(page), sort, int64(amount), searchQuery, group.Id)
+			results, err := batch.ModelsList(lang, int64(page), sort, int64(amount), searchQuery, group.Id).Remember().Result()
 			if err != nil {
 				log.Println(err)
 				return &pongo2.Error{Sender: "tag:fetch", OrigError: err}
@@ -474,8 +482,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 		}
 		if cacheTimeout > 0 {
 			cached, err := db.GetCachedTimeout(cacheKey, cacheTimeout, cacheTimeout, func() ([]byte, error) {
-				_, rawResponse, err := api.ChannelsList(config, lang, int64(page), sort, int64(amount), group.Id)
-				return rawResponse, err
+				return batch.ChannelsList(lang, int64(page), sort, int64(amount), group.Id).RememberCached(cacheKey).Raw()
 			}, nocache)
 			if err != nil {
 				log.Println(err)
@@ -489,7 +496,7 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				fetchContext.Private["channels"] = results
 			}
 		} else {
-			results, _, err := api.ChannelsList(config, lang, int64(page), sort, int64(amount), group.Id)
+			results, err := batch.ChannelsList(lang, int64(page), sort, int64(amount), group.Id).Remember().Result()
 			if err != nil {
 				log.Println(err)
 				return &pongo2.Error{Sender: "tag:fetch", OrigError: err}
@@ -562,14 +569,10 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 	case "searches":
 		if cacheTimeout > 0 {
 			cached, err := db.GetCachedTimeout(cacheKey, cacheTimeout, cacheTimeout, func() ([]byte, error) {
-				var rawResponse []byte
-				var err error
 				if sort == api.SortRand {
-					_, rawResponse, err = api.RandomSearches(config, lang, int64(amount), int64(minSearches))
-				} else {
-					_, rawResponse, err = api.TopSearches(config, lang, int64(amount))
+					return batch.RandomSearches(lang, int64(amount), int64(minSearches)).RememberCached(cacheKey).Raw()
 				}
-				return rawResponse, err
+				return batch.TopSearches(lang, int64(amount)).RememberCached(cacheKey).Raw()
 			}, nocache)
 			if err != nil {
 				log.Println(err)
@@ -585,12 +588,12 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 				fetchContext.Private["searches"] = result.Items
 			}
 		} else {
-			var results []types.TopSearch
+			var results interface{}
 			var err error
 			if sort == api.SortRand {
-				results, _, err = api.RandomSearches(config, lang, int64(amount), int64(minSearches))
+				results, err = batch.RandomSearches(lang, int64(amount), int64(minSearches)).Remember().Result()
 			} else {
-				results, _, err = api.TopSearches(config, lang, int64(amount))
+				results, err = batch.TopSearches(lang, int64(amount)).Remember().Result()
 			}
 			if err != nil {
 				log.Println(err)
@@ -603,6 +606,15 @@ func (node *tagFetchNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Te
 	return Err
 }
 
+// fetchBatch returns the api batch of the page render, so minion requests of the tag are sent together
+// with the other requests of the page. Requests to other urls are not batched.
+func fetchBatch(ctx *pongo2.ExecutionContext, config *types.Config) *api.Batch {
+	if batch, ok := ctx.Public["api_batch"].(*api.Batch); ok {
+		return batch
+	}
+	return api.NewBatch(config)
+}
+
 func pongo2Fetch(doc *pongo2.Parser, _ *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
 	tagFetch := &tagFetchNode{
 		headers: make([]pongo2.IEvaluator, 0, 5),
@@ -247,6 +247,10 @@ func InitPongo2() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	err = pongo2.RegisterTag("cache", pongo2Cache)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	err = pongo2.RegisterTag("link", pongo2Link)
 	if err != nil {
 		log.Fatalln(err)
@@ -56,6 +56,7 @@ func postHook(parsed []byte, name, path string, config *types.Config, c pongo2.C
 			var v goja.Value
 			v, err = vm.RunProgram(program)
 			if err != nil {
+				traceError(config, "extension", m, err, c)
 				log.Println(err, path, name, config.Hostname)
 				return
 			}
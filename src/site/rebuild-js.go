@@ -18,8 +18,9 @@ var rebuildJSMutex sync.Mutex
 func RebuildJS(path string, config *types.Config) error {
 	rebuildJSMutex.Lock()
 	defer rebuildJSMutex.Unlock()
-	var entryFiles = make([]string, 0, len(config.Javascript.Entries))
-	for _, e := range config.Javascript.Entries {
+	entries := jsEntries(config)
+	var entryFiles = make([]string, 0, len(entries))
+	for _, e := range entries {
 		entryFile := filepath.Join(path, e)
 		if _, err := os.Stat(entryFile); err != nil {
 			err := fmt.Errorf("can't access entry file %s: %s", entryFile, err.Error())
@@ -23,7 +23,7 @@ var cssMinifier *minify.M
 func RebuildSCSS(path string, config *types.Config) error {
 	rebuildSCSSMutex.Lock()
 	defer rebuildSCSSMutex.Unlock()
-	for _, entryBase := range config.Scss.Entries {
+	for _, entryBase := range scssEntries(config) {
 		entry := filepath.Join(path, entryBase)
 		outName := strings.TrimSuffix(entryBase, filepath.Ext(entryBase)) + ".css"
 		outDir := filepath.Join(path, "../public", config.Scss.Destination)
@@ -0,0 +1,22 @@
+package site
+
+import (
+	"net/http"
+	"time"
+
+	"sersh.com/totaltube/frontend/types"
+)
+
+// routeOptions returns [route_options] of the route being served.
+func routeOptions(r *http.Request) (types.RouteOptions, bool) {
+	options, ok := r.Context().Value(types.ContextKeyRouteOptions).(types.RouteOptions)
+	return options, ok
+}
+
+// routeCacheTtl returns rendered page cache timeout of the route, if it's set in [route_options].
+func routeCacheTtl(r *http.Request, cacheTtl time.Duration) time.Duration {
+	if options, ok := routeOptions(r); ok && options.CacheTimeout != nil {
+		return time.Duration(*options.CacheTimeout)
+	}
+	return cacheTtl
+}
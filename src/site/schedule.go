@@ -0,0 +1,122 @@
+package site
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"sersh.com/totaltube/frontend/api"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// scssEntries returns scss entries of the active theme or of the site.
+func scssEntries(config *types.Config) []string {
+	if s := config.ActiveSchedule(time.Now()); s != nil && len(s.ScssEntries) > 0 {
+		return s.ScssEntries
+	}
+	return config.Scss.Entries
+}
+
+// jsEntries returns javascript entries of the active theme or of the site.
+func jsEntries(config *types.Config) []string {
+	if s := config.ActiveSchedule(time.Now()); s != nil && len(s.JsEntries) > 0 {
+		return s.JsEntries
+	}
+	return config.Javascript.Entries
+}
+
+// ThemeStaticPath returns the file in static overlay directory of the active theme if it exists there.
+func ThemeStaticPath(sitePath string, config *types.Config, filePath string) (string, bool) {
+	s := config.ActiveSchedule(time.Now())
+	if s == nil || s.Static == "" {
+		return "", false
+	}
+	p := filepath.Join(sitePath, s.Static, filepath.FromSlash(path.Clean("/"+filePath)))
+	if info, err := os.Stat(p); err != nil || info.IsDir() {
+		return "", false
+	}
+	return p, true
+}
+
+// WatchSchedule switches [[schedule]] themes of the site at their start and end: clears output cache of the templates
+// overridden by the themes and rebuilds css and js bundles.
+func WatchSchedule(sitePath string, configPath string) {
+	done := watchDone(sitePath)
+	go func() {
+		config := internal.GetConfig(configPath, api.UpdateConfigRetry)
+		active := config.ActiveSchedule(time.Now())
+		for {
+			// config can be changed, so it's checked at least every minute
+			wait := time.Minute
+			if next := config.NextScheduleChange(time.Now()); !next.IsZero() && time.Until(next) < wait {
+				wait = time.Until(next)
+			}
+			select {
+			case <-time.After(wait):
+			case <-done:
+				// site is removed
+				return
+			}
+			if _, err := os.Stat(configPath); os.IsNotExist(err) {
+				// site is removed
+				return
+			}
+			config = internal.GetConfig(configPath, api.UpdateConfigRetry)
+			current := config.ActiveSchedule(time.Now())
+			if reflect.DeepEqual(active, current) {
+				continue
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Println("recovered in switching theme of", sitePath, r)
+					}
+				}()
+				switchSchedule(sitePath, config, active, current)
+			}()
+			active = current
+		}
+	}()
+}
+
+func switchSchedule(sitePath string, config *types.Config, previous, current *types.ConfigSchedule) {
+	rebuildScss, rebuildJs := false, false
+	ts := siteTemplates.forPath(sitePath, TemplatesDir)
+	for _, s := range []*types.ConfigSchedule{previous, current} {
+		if s == nil {
+			continue
+		}
+		if s == current {
+			log.Println(config.Hostname, "theme", s.Name, "started")
+		} else {
+			log.Println(config.Hostname, "theme", s.Name, "ended")
+		}
+		if s.Templates != "" {
+			ts.clearThemeCache(s.Templates)
+		}
+		rebuildScss = rebuildScss || len(s.ScssEntries) > 0
+		rebuildJs = rebuildJs || len(s.JsEntries) > 0
+	}
+	ts.Lock()
+	ts.reset()
+	ts.Unlock()
+	if scssPath := filepath.Join(sitePath, "scss"); rebuildScss && dirExists(scssPath) {
+		if err := RebuildSCSS(scssPath, config); err != nil {
+			log.Println("Error rebuilding scss:", err)
+		}
+	}
+	if jsPath := filepath.Join(sitePath, "js"); rebuildJs && dirExists(jsPath) {
+		if err := RebuildJS(jsPath, config); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func dirExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.IsDir()
+}
@@ -102,7 +102,8 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 		return
 	}
 	hostName := customContext["host"].(string)
-	cacheKey := "custom:" + hostName + ":" + name + ":" + v.String()
+	cacheKey := outputCachePrefix(hostName, "custom-"+name) + contextLang(customContext) + ":" +
+		deviceCacheKey(w, v.String(), contextDevice(customContext))
 	program, err = getJsProgram(name+":cacheTtl", string(source)+" cacheTtl()")
 	if err != nil {
 		log.Println(err)
@@ -113,7 +114,7 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 		log.Println(err)
 		return
 	}
-	cacheTtl := time.Duration(v.ToInteger()) * time.Second
+	cacheTtl := routeCacheTtl(r, time.Duration(v.ToInteger())*time.Second)
 	var addDynamicFunctions = func(ctx pongo2.Context) {
 		ctx["set_cookie"] = func(name string, value interface{}, expire interface{}) {
 			var expires = time.Now().Add(time.Minute * 60)
@@ -282,8 +283,11 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 					log.Println(err)
 					return nil
 				}
+				started := time.Now()
 				v, err := vm.RunProgram(program)
+				config.Trace().AddCall("extension", funcName, started, err)
 				if err != nil {
+					traceError(config, "extension", m, err, c)
 					log.Println(err)
 					return nil
 				}
@@ -294,6 +298,7 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 
 	var ctx pongo2.Context
 	recreate := func() (parsed []byte, err error) {
+		prefetchBatch("custom/"+name, customContext, nocache)
 		var prepareCtx map[string]interface{}
 		prepareCtx, err = func() (prepareCtx map[string]interface{}, err error) {
 			// first - run prepare() function
@@ -322,8 +327,11 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 				log.Println(err)
 				return
 			}
+			started := time.Now()
 			v, err = vm.RunProgram(program)
+			config.Trace().AddCall("extension", "route-"+name+".js prepare()", started, err)
 			if err != nil {
+				traceError(config, "extension", extensionFile, err, customContext)
 				log.Println(err)
 				return
 			}
@@ -366,18 +374,23 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 			log.Println(err)
 			return
 		}
+		started := time.Now()
 		v, err = vm.RunProgram(program)
 		if err != nil {
 			// Перехватываем Goja-исключение, если оно является ErrSendResponse
 			if gojaErr, ok := err.(*goja.Exception); ok {
 				if sendErr, isSendErr := gojaErr.Value().Export().(ErrSendResponse); isSendErr {
+					config.Trace().AddCall("extension", "route-"+name+".js render()", started, nil)
 					err = sendErr // Пробрасываем нашу кастомную ошибку дальше
 					return
 				}
 			}
+			config.Trace().AddCall("extension", "route-"+name+".js render()", started, err)
+			traceError(config, "extension", extensionFile, err, ctx)
 			log.Println(err)
 			return
 		}
+		config.Trace().AddCall("extension", "route-"+name+".js render()", started, nil)
 		if ret, ok := v.Export().(map[string]any); ok {
 			// if render() returns object - output it as json
 			e := ErrSendResponse{JSON: ret}
@@ -428,12 +441,18 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 			return
 		}
 		var template *pongo2.Template
-		template, err = GetTemplate("custom-"+name, path)
+		template, err = getContextTemplate("custom-"+name, path, ctx)
 		if err != nil {
+			if err != ErrTemplateNotFound {
+				traceError(config, "template", filepath.Join(path, "templates", "custom-"+name+".twig"), err, ctx)
+			}
 			return
 		}
+		started = time.Now()
 		parsed, err = template.ExecuteBytes(ctx)
+		config.Trace().AddRenderTime(time.Since(started), 0)
 		if err != nil {
+			traceError(config, "template", filepath.Join(path, "templates", "custom-"+name+".twig"), err, ctx)
 			return
 		}
 		if config.General.MinifyHtml {
@@ -442,9 +461,15 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 				log.Println("can't minify html:", err, name, path, config.Hostname)
 			}
 		}
+		parsed = PrepareDynamic(parsed)
 		return
 	}
 
+	if contextPreview(customContext) {
+		// draft templates must not get to the cache
+		cacheTtl = 0
+	}
+	traceTemplate(config, "custom-"+name, cacheKey, cacheTtl, nocache, customContext)
 	if cacheTtl > 0 {
 		if parsed, err = db.GetCachedTimeout(cacheKey, cacheTtl, time.Duration(math.Max(float64(time.Second*5), float64(cacheTtl/2))), recreate, nocache); err != nil {
 			return
@@ -452,15 +477,29 @@ func ParseCustomTemplate(name, path string, config *types.Config,
 		c := generateContext(name, path, customContext)
 		addCustomFunctions(c)
 		//addDynamicFunctions(c)
+		started := time.Now()
 		parsed = InsertDynamic(parsed, path, c)
+		config.Trace().AddRenderTime(0, time.Since(started))
 		parsed = postHook(parsed, name, path, config, c, nocache)
+		if err = traceErrorResult(config); err != nil {
+			return
+		}
+		setCacheHeaders(w, r, config, "custom-"+name, customContext)
+		parsed = insertDebugToolbar(parsed, config)
 		return
 	}
 	if parsed, err = recreate(); err != nil {
 		return
 	}
 	addDynamicFunctions(ctx)
+	started := time.Now()
 	parsed = InsertDynamic(parsed, path, ctx)
+	config.Trace().AddRenderTime(0, time.Since(started))
 	parsed = postHook(parsed, name, path, config, ctx, nocache)
+	if err = traceErrorResult(config); err != nil {
+		return
+	}
+	setCacheHeaders(w, r, config, "custom-"+name, customContext)
+	parsed = insertDebugToolbar(parsed, config)
 	return
 }
@@ -0,0 +1,76 @@
+package site
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// dependencyLoader records include/extends/import graph of the templates while pongo2 parses them.
+type dependencyLoader struct {
+	pongo2.TemplateLoader
+	mu   sync.Mutex
+	deps map[string]map[string]struct{} // template file -> files it includes, extends or imports
+}
+
+func newDependencyLoader(loader pongo2.TemplateLoader) *dependencyLoader {
+	return &dependencyLoader{TemplateLoader: loader, deps: make(map[string]map[string]struct{})}
+}
+
+func (l *dependencyLoader) Abs(base, name string) string {
+	resolved := l.TemplateLoader.Abs(base, name)
+	if base == "" {
+		return resolved
+	}
+	absBase, err1 := filepath.Abs(base)
+	absResolved, err2 := filepath.Abs(resolved)
+	if err1 != nil || err2 != nil || absBase == absResolved {
+		return resolved
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.deps[absBase] == nil {
+		l.deps[absBase] = make(map[string]struct{})
+	}
+	l.deps[absBase][absResolved] = struct{}{}
+	return resolved
+}
+
+// dependents returns the file and all template files which include, extend or import it, directly or transitively.
+func (l *dependencyLoader) dependents(file string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reverse := make(map[string][]string)
+	for base, deps := range l.deps {
+		for dep := range deps {
+			reverse[dep] = append(reverse[dep], base)
+		}
+	}
+	seen := map[string]bool{file: true}
+	queue := []string{file}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, base := range reverse[current] {
+			if !seen[base] {
+				seen[base] = true
+				queue = append(queue, base)
+			}
+		}
+	}
+	result := make([]string, 0, len(seen))
+	for f := range seen {
+		result = append(result, f)
+	}
+	return result
+}
+
+// outputCachePrefix returns the prefix of rendered output cache keys of the template.
+func outputCachePrefix(host, templateName string) string {
+	if strings.HasPrefix(templateName, "custom-") {
+		return "custom:" + host + ":" + strings.TrimPrefix(templateName, "custom-") + ":"
+	}
+	return "out:" + host + ":" + templateName + ":"
+}
@@ -0,0 +1,109 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/types"
+)
+
+// layeredLoader resolves templates through the layers of directories: templates/<lang>/name.twig overrides
+// templates/name.twig, theme overlay directory overrides both. It works for rendered templates as well as
+// for included, extended and imported ones.
+type layeredLoader struct {
+	pongo2.TemplateLoader
+	roots []string // absolute paths of the layers, the first one has the priority; the last one is templates directory
+}
+
+func newLayeredLoader(loader pongo2.TemplateLoader, roots ...string) *layeredLoader {
+	l := &layeredLoader{TemplateLoader: loader}
+	for _, root := range roots {
+		abs, _ := filepath.Abs(root)
+		l.roots = append(l.roots, abs)
+	}
+	return l
+}
+
+func (l *layeredLoader) Abs(base, name string) string {
+	resolved := l.TemplateLoader.Abs(base, name)
+	absResolved, err := filepath.Abs(resolved)
+	if err != nil {
+		return resolved
+	}
+	// path relative to the deepest layer the file is in
+	rel := ""
+	for _, root := range l.roots {
+		r, err := filepath.Rel(root, absResolved)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "" || len(r) < len(rel) {
+			rel = r
+		}
+	}
+	if rel == "" {
+		return resolved
+	}
+	for _, root := range l.roots {
+		override := filepath.Join(root, rel)
+		if _, err = os.Stat(override); err == nil {
+			return override
+		}
+	}
+	return filepath.Join(l.roots[len(l.roots)-1], rel)
+}
+
+// languageDirs returns languages which have overrides directory in templates path.
+func languageDirs(templatesPath string) (langs []string) {
+	entries, _ := os.ReadDir(templatesPath)
+	for _, e := range entries {
+		if e.IsDir() && internal.GetLanguage(e.Name()) != nil {
+			langs = append(langs, e.Name())
+		}
+	}
+	return
+}
+
+// contextLang returns language id of the template context.
+func contextLang(ctx pongo2.Context) string {
+	if lang, ok := ctx["lang"].(*types.Language); ok && lang != nil {
+		return lang.Id
+	}
+	return ""
+}
+
+// contextTheme returns templates overlay directory of the theme active now.
+func contextTheme(ctx pongo2.Context) string {
+	if config, ok := ctx["config"].(*types.Config); ok && config != nil {
+		return config.ActiveTemplates(time.Now())
+	}
+	return ""
+}
+
+// contextPreview returns true for requests with valid preview token, which are rendered with draft templates
+// and without output cache.
+func contextPreview(ctx pongo2.Context) bool {
+	preview, _ := ctx["preview"].(bool)
+	return preview
+}
+
+// getContextTemplate returns the template for the theme, language and device of the context, with fallback
+// to the template without device variant. Preview requests get templates from templates-draft if it exists.
+func getContextTemplate(name, path string, ctx pongo2.Context) (*pongo2.Template, error) {
+	dir := TemplatesDir
+	if contextPreview(ctx) && dirExists(filepath.Join(path, DraftTemplatesDir)) {
+		dir = DraftTemplatesDir
+	}
+	theme, lang := contextTheme(ctx), contextLang(ctx)
+	if device := contextDevice(ctx); device != "" {
+		if t, err := siteTemplates.get(name+"."+device, path, dir, theme, lang); err != ErrTemplateNotFound {
+			return t, err
+		}
+	}
+	return siteTemplates.get(name, path, dir, theme, lang)
+}
@@ -1,11 +1,15 @@
 package site
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
 	"log"
 	"math"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,6 +18,7 @@ import (
 
 	"github.com/dop251/goja"
 	"github.com/samber/lo"
+	"sersh.com/totaltube/frontend/api"
 	"sersh.com/totaltube/frontend/geoip"
 	"sersh.com/totaltube/frontend/types"
 
@@ -28,102 +33,216 @@ import (
 
 var ErrTemplateNotFound = errors.New("template not found")
 
+// Templates directory of the site and the directory with draft templates, used only for preview requests.
+const (
+	TemplatesDir      = "templates"
+	DraftTemplatesDir = "templates-draft"
+)
+
 type templates struct {
 	sync.Mutex
-	path        string
-	templates   map[string]*pongo2.Template
-	templateSet *pongo2.TemplateSet
-	lastChange  time.Time
+	path         string
+	dir          string                      // templates directory name: templates or templates-draft
+	templates    map[string]*pongo2.Template // by theme, language and name
+	templateSets map[string]*pongo2.TemplateSet
+	loaders      map[string]*dependencyLoader
+	dirs         map[string]bool
+	lastChange   time.Time
+	changed      []string      // files changed since the last reset
+	done         chan struct{} // closed when the site is removed
+}
+
+// layers returns directories where templates of the theme and language are looked up, by priority.
+func (ts *templates) layers(theme, lang string) (dirs []string) {
+	templatesPath := filepath.Join(ts.path, ts.dir)
+	if theme != "" {
+		if lang != "" {
+			dirs = append(dirs, filepath.Join(ts.path, theme, lang))
+		}
+		dirs = append(dirs, filepath.Join(ts.path, theme))
+	}
+	if lang != "" {
+		dirs = append(dirs, filepath.Join(templatesPath, lang))
+	}
+	return append(dirs, templatesPath)
 }
 
-func (ts *templates) get(name string) (*pongo2.Template, error) {
+// templateSet returns template set of the theme and language. Templates of the languages without overrides directory
+// are in the set of empty language.
+func (ts *templates) templateSet(theme, lang string) (*pongo2.TemplateSet, *dependencyLoader) {
+	key := theme + "|" + lang
+	if set, ok := ts.templateSets[key]; ok {
+		return set, ts.loaders[key]
+	}
+	var loader *dependencyLoader
+	name := filepath.Base(ts.path)
+	if ts.dir != TemplatesDir {
+		name += ":" + ts.dir
+	}
+	if theme == "" && lang == "" {
+		loader = newDependencyLoader(pongo2.DefaultLoader)
+	} else {
+		loader = newDependencyLoader(newLayeredLoader(pongo2.DefaultLoader, ts.layers(theme, lang)...))
+		name += ":" + key
+	}
+	set := pongo2.NewSet(name, loader)
+	set.Options.LStripBlocks = true
+	set.Options.TrimBlocks = true
+	ts.templateSets[key] = set
+	ts.loaders[key] = loader
+	return set, loader
+}
+
+func (ts *templates) hasDir(dir string) bool {
+	if has, ok := ts.dirs[dir]; ok {
+		return has
+	}
+	info, err := os.Stat(filepath.Join(ts.path, dir))
+	ts.dirs[dir] = err == nil && info.IsDir()
+	return ts.dirs[dir]
+}
+
+func (ts *templates) get(name, theme, lang string) (*pongo2.Template, error) {
 	ts.Lock()
 	defer ts.Unlock()
-	if t, ok := ts.templates[name]; ok {
+	if theme != "" && !ts.hasDir(theme) {
+		theme = ""
+	}
+	if lang != "" && !ts.hasDir(filepath.Join(ts.dir, lang)) && (theme == "" || !ts.hasDir(filepath.Join(theme, lang))) {
+		lang = ""
+	}
+	key := theme + "|" + lang + "/" + name
+	if t, ok := ts.templates[key]; ok {
+		if t == nil {
+			return nil, ErrTemplateNotFound
+		}
 		return t, nil
 	}
 	// Парсим шаблон
-	matches, err := filepath.Glob(filepath.Join(ts.path, "templates", "*"))
-	if err != nil {
-		return nil, errors.Wrap(err, "can't open "+ts.path)
-	}
-	for _, m := range matches {
-		if strings.Split(filepath.Base(m), ".")[0] == name {
-			// Found the template
-			template, err := ts.templateSet.FromFile(m)
-			if err != nil {
-				return nil, err
+	for _, dir := range ts.layers(theme, lang) {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			return nil, errors.Wrap(err, "can't open "+ts.path)
+		}
+		for _, m := range matches {
+			if templateFileMatches(filepath.Base(m), name) {
+				// Found the template
+				set, _ := ts.templateSet(theme, lang)
+				template, err := set.FromFile(m)
+				if err != nil {
+					return nil, err
+				}
+				ts.templates[key] = template
+				return template, nil
 			}
-			return template, nil
 		}
 	}
-	if name != "sitemap-video" {
+	// missing device variants are looked up on every request, so not found is cached too until templates change
+	ts.templates[key] = nil
+	if name != "sitemap-video" && !isDeviceClass(name[strings.LastIndex(name, ".")+1:]) {
 		log.Println(name, "template not found")
 	}
 	return nil, ErrTemplateNotFound
 }
 
-func NewTemplates(path string) *templates {
-	n := templates{path: path, templates: make(map[string]*pongo2.Template)}
-	n.templateSet = pongo2.NewSet(filepath.Base(path), pongo2.DefaultLoader)
-	n.templateSet.Options.LStripBlocks = true
-	n.templateSet.Options.TrimBlocks = true
-	host := filepath.Base(path)
+func (ts *templates) reset() {
+	ts.templates = make(map[string]*pongo2.Template)
+	ts.dirs = make(map[string]bool)
+}
+
+// siteConfig returns config of the site, nil if there is no config file.
+func (ts *templates) siteConfig() *types.Config {
+	configPath := filepath.Join(ts.path, "config.toml")
+	if _, err := os.Stat(configPath); err != nil {
+		return nil
+	}
+	return internal.GetConfig(configPath, api.UpdateConfigRetry)
+}
+
+func NewTemplates(path, dir string) *templates {
+	n := templates{path: path, dir: dir, templateSets: make(map[string]*pongo2.TemplateSet), loaders: make(map[string]*dependencyLoader),
+		done: make(chan struct{})}
+	n.reset()
+	absSitePath, _ := filepath.Abs(path)
 	go func() {
 		for {
+			select {
+			case <-n.done:
+				return
+			default:
+			}
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
 						log.Println("error in templates file watching routine", r)
 					}
 				}()
+				if !dirExists(filepath.Join(path, dir)) {
+					time.Sleep(time.Second * 5)
+					return
+				}
 				c := make(chan notify.EventInfo, 1)
-				if err := notify.Watch(filepath.Join(path, "templates")+"/...", c, notify.Create,
+				if err := notify.Watch(filepath.Join(path, dir)+"/...", c, notify.Create,
 					notify.Write, notify.Remove, notify.Rename); err != nil {
 					log.Panicln(err)
 				}
-				defer notify.Stop(c)
-				// waiting for signal of file changing
-				info := <-c
-				if internal.Config.General.Development {
-					// In dev mode we invalidate all cache
-					err := db.ClearCacheByPrefix("")
-					if err != nil {
-						log.Println(err)
-					}
-				} else {
-					var changedTemplatePath = info.Path()
-					abs, _ := filepath.Abs(filepath.Join(path, "templates"))
-					if filepath.Dir(changedTemplatePath) == abs && filepath.Ext(changedTemplatePath) == ".twig" {
-						templateName := strings.TrimSuffix(filepath.Base(changedTemplatePath), filepath.Ext(changedTemplatePath))
-						switch templateName {
-						case "top-categories", "category", "top-content", "404", "500",
-							"model", "models", "content-item", "search", "fake-player", "sitemap-video":
-							err := db.ClearCacheByPrefix(templateName + ":" + host + ":")
-							if err != nil {
-								log.Println(err)
-							}
+				// the whole templates directory can be replaced, like by promote command
+				if err := notify.Watch(path, c, notify.Create, notify.Remove, notify.Rename); err != nil {
+					log.Panicln(err)
+				}
+				// templates overlays of scheduled themes
+				if config := n.siteConfig(); config != nil {
+					for _, s := range config.Schedule {
+						if s.Templates == "" || !dirExists(filepath.Join(path, s.Templates)) {
+							continue
 						}
-						if strings.HasPrefix(templateName, "custom-") {
-							err := db.ClearCacheByPrefix("custom:" + host + ":" + strings.TrimPrefix(templateName, "custom-") + ":")
-							if err != nil {
-								log.Println(err)
-							}
+						if err := notify.Watch(filepath.Join(path, s.Templates)+"/...", c, notify.Create,
+							notify.Write, notify.Remove, notify.Rename); err != nil {
+							log.Panicln(err)
 						}
 					}
 				}
+				defer notify.Stop(c)
+				// waiting for signal of file changing
+				var info notify.EventInfo
+				select {
+				case info = <-c:
+				case <-n.done:
+					return
+				}
+				siteDirChanged := filepath.Dir(info.Path()) == absSitePath
+				if siteDirChanged && filepath.Base(info.Path()) != dir {
+					return
+				}
+				if dir == TemplatesDir {
+					// versions of live templates are kept in the history
+					if siteDirChanged {
+						SnapshotSite(path)
+					} else {
+						SnapshotFile(path, info.Path())
+					}
+				}
+				// parsed templates are dropped at once, so pages rendered meanwhile don't use the old ones
 				n.Lock()
+				n.reset()
 				n.lastChange = time.Now()
+				n.changed = append(n.changed, info.Path())
 				n.Unlock()
-				// After 1.5 seconds after last change we invalidate all template cache
+				// After 1.5 seconds after last change we invalidate all template cache. Output cache is cleared
+				// after the reset, so pages rendered from partly written files don't stay in the cache.
 				go func() {
 					time.Sleep(time.Millisecond * 1500)
 					n.Lock()
-					defer n.Unlock()
-					if !n.lastChange.After(time.Now().Add(-time.Millisecond * 1500)) {
-						n.lastChange = time.Now()
-						n.templates = make(map[string]*pongo2.Template)
+					if n.lastChange.After(time.Now().Add(-time.Millisecond * 1500)) {
+						n.Unlock()
+						return
 					}
+					n.lastChange = time.Now()
+					n.reset()
+					changed := n.changed
+					n.changed = nil
+					n.Unlock()
+					n.clearChanged(absSitePath, changed)
 				}()
 			}()
 		}
@@ -131,26 +250,184 @@ func NewTemplates(path string) *templates {
 	return &n
 }
 
+// clearChanged clears output cache of the pages depending on the changed files.
+func (ts *templates) clearChanged(absSitePath string, changed []string) {
+	if internal.Config.General.Development {
+		// In dev mode we invalidate all cache
+		if err := db.ClearCacheByPrefix(""); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	if ts.dir != TemplatesDir {
+		// draft templates are rendered without output cache
+		return
+	}
+	var twigs []string
+	for _, p := range changed {
+		if filepath.Dir(p) == absSitePath {
+			// the whole templates directory is replaced
+			clearSiteCache(filepath.Base(ts.path))
+			return
+		}
+		if filepath.Ext(p) == ".twig" && !lo.Contains(twigs, p) {
+			twigs = append(twigs, p)
+		}
+	}
+	if len(twigs) > 0 {
+		ts.clearOutputCache(twigs...)
+	}
+}
+
+// clearOutputCache clears rendered output cache of every template which depends on the changed files.
+// Files of templates/<lang> directory clear only pages of the language.
+func (ts *templates) clearOutputCache(changedPaths ...string) {
+	host := filepath.Base(ts.path)
+	templatesPath, _ := filepath.Abs(filepath.Join(ts.path, ts.dir))
+	for i := range changedPaths {
+		changedPaths[i], _ = filepath.Abs(changedPaths[i])
+	}
+	// cached fragments can't be bound to templates, so all of them are cleared
+	if err := db.ClearCacheByPrefix(fragmentCachePrefix + host + ":"); err != nil {
+		log.Println(err)
+	}
+	themes := []string{""}
+	if config := ts.siteConfig(); config != nil {
+		if theme := config.ActiveTemplates(time.Now()); theme != "" {
+			themes = append(themes, theme)
+		}
+	}
+	for _, theme := range themes {
+		for _, lang := range append([]string{""}, languageDirs(templatesPath)...) {
+			var rootPaths []string
+			for _, dir := range ts.layers(theme, lang) {
+				dir, _ = filepath.Abs(dir)
+				rootPaths = append(rootPaths, dir)
+			}
+			// parsing all templates to have the full dependency graph, even of the templates not rendered yet
+			ts.Lock()
+			set, loader := ts.templateSet(theme, lang)
+			for _, root := range rootPaths {
+				matches, _ := filepath.Glob(filepath.Join(root, "*.twig"))
+				for _, m := range matches {
+					_, _ = set.FromFile(m)
+				}
+			}
+			ts.Unlock()
+			for _, changedPath := range changedPaths {
+				for _, file := range loader.dependents(changedPath) {
+					if !lo.Contains(rootPaths, filepath.Dir(file)) {
+						continue
+					}
+					prefix := outputCachePrefix(host, strings.Split(filepath.Base(file), ".")[0])
+					if lang != "" {
+						prefix += lang + ":"
+					}
+					if err := db.ClearCacheByPrefix(prefix); err != nil {
+						log.Println(err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// clearSiteCache clears all rendered output and fragments cache of the site.
+func clearSiteCache(host string) {
+	for _, prefix := range []string{"out:" + host + ":", "custom:" + host + ":", fragmentCachePrefix + host + ":"} {
+		if err := db.ClearCacheByPrefix(prefix); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// clearThemeCache clears output cache of the templates overridden by the theme overlay directory
+// and of the templates depending on them.
+func (ts *templates) clearThemeCache(theme string) {
+	themePath := filepath.Join(ts.path, theme)
+	var changed []string
+	_ = filepath.WalkDir(themePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".twig" {
+			return nil
+		}
+		rel, _ := filepath.Rel(themePath, p)
+		changed = append(changed, p, filepath.Join(ts.path, ts.dir, rel))
+		return nil
+	})
+	if len(changed) > 0 {
+		ts.clearOutputCache(changed...)
+	}
+}
+
 type siteTemplatesT struct {
 	sync.Mutex
 	siteTemplates map[string]*templates
 }
 
-func (st *siteTemplatesT) get(name, path string) (*pongo2.Template, error) {
+func (st *siteTemplatesT) forPath(path, dir string) *templates {
 	st.Lock()
-	if ts, ok := st.siteTemplates[path]; ok {
-		st.Unlock()
-		return ts.get(name)
+	defer st.Unlock()
+	key := filepath.Join(path, dir)
+	if ts, ok := st.siteTemplates[key]; ok {
+		return ts
 	}
-	st.siteTemplates[path] = NewTemplates(path)
-	st.Unlock()
-	return st.siteTemplates[path].get(name)
+	st.siteTemplates[key] = NewTemplates(path, dir)
+	return st.siteTemplates[key]
+}
+
+func (st *siteTemplatesT) get(name, path, dir, theme, lang string) (*pongo2.Template, error) {
+	return st.forPath(path, dir).get(name, theme, lang)
 }
 
 var siteTemplates = siteTemplatesT{siteTemplates: map[string]*templates{}}
 
+// siteWatchers keeps channels closed when the site is removed, so js, scss and schedule watchers of the site stop.
+var siteWatchers = struct {
+	sync.Mutex
+	done map[string]chan struct{}
+}{done: map[string]chan struct{}{}}
+
+// watchDone returns the channel closed by ForgetSite for watchers of the site.
+func watchDone(sitePath string) chan struct{} {
+	sitePath = filepath.Clean(sitePath)
+	siteWatchers.Lock()
+	defer siteWatchers.Unlock()
+	done, ok := siteWatchers.done[sitePath]
+	if !ok {
+		done = make(chan struct{})
+		siteWatchers.done[sitePath] = done
+	}
+	return done
+}
+
+// ForgetSite stops watching templates, js, scss and schedule of the removed site and clears its rendered pages cache.
+func ForgetSite(sitePath string) {
+	siteTemplates.Lock()
+	for _, dir := range []string{TemplatesDir, DraftTemplatesDir} {
+		key := filepath.Join(sitePath, dir)
+		if ts, ok := siteTemplates.siteTemplates[key]; ok {
+			close(ts.done)
+			delete(siteTemplates.siteTemplates, key)
+		}
+	}
+	siteTemplates.Unlock()
+	siteWatchers.Lock()
+	if done, ok := siteWatchers.done[filepath.Clean(sitePath)]; ok {
+		close(done)
+		delete(siteWatchers.done, filepath.Clean(sitePath))
+	}
+	siteWatchers.Unlock()
+	clearSiteCache(filepath.Base(sitePath))
+}
+
+// WatchTemplates starts watching live templates of the site before the first page is rendered,
+// so versions of all template changes get to the history.
+func WatchTemplates(sitePath string) {
+	siteTemplates.forPath(sitePath, TemplatesDir)
+}
+
 func GetTemplate(name, path string) (*pongo2.Template, error) {
-	return siteTemplates.get(name, path)
+	return siteTemplates.get(name, path, TemplatesDir, "", "")
 }
 
 func ParseTemplate(name, path string, config *types.Config, customContext pongo2.Context,
@@ -306,8 +583,11 @@ func ParseTemplate(name, path string, config *types.Config, customContext pongo2
 					return nil
 				}
 				var v goja.Value
+				started := time.Now()
 				v, err = vm.RunProgram(program)
+				config.Trace().AddCall("extension", funcName, started, err)
 				if err != nil {
+					traceError(config, "extension", m, err, c)
 					log.Println(err, path, name, config.Hostname, funcName+"("+argsString+")")
 					return nil
 				}
@@ -315,7 +595,15 @@ func ParseTemplate(name, path string, config *types.Config, customContext pongo2
 			}
 		}
 	}
+	cacheTtl = routeCacheTtl(r, cacheTtl)
 	var extendedTtl = time.Duration(math.Max(float64(time.Minute*5), float64(cacheTtl)))
+	if contextPreview(customContext) {
+		// draft templates must not get to the cache
+		cacheTtl = 0
+	}
+	// language is a separate part of the key, so changes of templates/<lang> clear only pages of the language
+	outputKey := outputCachePrefix(config.Hostname, name) + contextLang(customContext) + ":" +
+		deviceCacheKey(w, cacheKey, contextDevice(customContext))
 	var dataCtx pongo2.Context
 	dataCtx, err = prepare()
 	if err != nil {
@@ -332,32 +620,38 @@ func ParseTemplate(name, path string, config *types.Config, customContext pongo2
 		customContextCopy[k] = v
 	}
 	recreateFunc := func() (result []byte, err error) {
+		prefetchBatch(name, customContextCopy, nocache)
 		c := generateContext(name, path, customContextCopy)
 		addCustomFunctions(c)
 		var template *pongo2.Template
-		template, err = GetTemplate(name, path)
+		template, err = getContextTemplate(name, path, c)
 		if err != nil {
 			if err != ErrTemplateNotFound {
+				traceError(config, "template", filepath.Join(path, "templates", name+".twig"), err, c)
 				log.Println(err, name, path, config.Hostname)
 			}
 			return
 		}
+		started := time.Now()
 		result, err = template.ExecuteBytes(c)
+		config.Trace().AddRenderTime(time.Since(started), 0)
 		if err != nil {
+			traceError(config, "template", filepath.Join(path, "templates", name+".twig"), err, c)
 			log.Println(err, name, path, config.Hostname)
 			return
 		}
-		// выведем строку из result, которая содержит слово dynamic
 		if config.General.MinifyHtml {
 			result, err = helpers.MinifyBytes(result)
 			if err != nil {
 				log.Println("can't minify html:", err, name, path, config.Hostname)
 			}
 		}
+		result = PrepareDynamic(result)
 		return
 	}
+	traceTemplate(config, name, outputKey, cacheTtl, nocache, customContext)
 	if cacheTtl > 0 {
-		cached, err = db.GetCachedTimeout(cacheKey, cacheTtl, extendedTtl, recreateFunc, nocache)
+		cached, err = db.GetCachedTimeout(outputKey, cacheTtl, extendedTtl, recreateFunc, nocache)
 	} else {
 		cached, err = recreateFunc()
 	}
@@ -370,7 +664,46 @@ func ParseTemplate(name, path string, config *types.Config, customContext pongo2
 	c := generateContext(name, path, customContext)
 	addCustomFunctions(c)
 	addDynamicFunctions(c)
+	started := time.Now()
 	parsed = InsertDynamic(cached, path, c)
+	config.Trace().AddRenderTime(0, time.Since(started))
 	parsed = postHook(parsed, name, path, config, c, nocache)
+	if err = traceErrorResult(config); err != nil {
+		return
+	}
+	setCacheHeaders(w, r, config, name, customContext)
+	parsed = insertDebugToolbar(parsed, config)
+	if config.Trace() == nil {
+		setValidators(w, parsed, outputKey, cacheTtl)
+	}
 	return
 }
+
+// prefetchBatch adds to the api batch of the page the calls its template made during previous renders.
+// It's called on output cache miss, calls with fresh results in db cache are not prefetched.
+func prefetchBatch(name string, customContext pongo2.Context, nocache bool) {
+	batch, ok := customContext["api_batch"].(*api.Batch)
+	if !ok {
+		return
+	}
+	host, _ := customContext["host"].(string)
+	batch.Prefetch(host+":"+contextLang(customContext)+":"+name, func(cacheKey string) bool {
+		return db.CacheFresh(cacheKey, nocache)
+	})
+}
+
+// setValidators sets strong ETag of the page bytes sent and Last-Modified header of the rendered page, so conditional
+// requests can be answered with 304 by middlewares.ConditionalGetMiddleware.
+func setValidators(w http.ResponseWriter, page []byte, cacheKey string, cacheTtl time.Duration) {
+	if w.Header().Get("ETag") != "" {
+		return
+	}
+	sum := md5.Sum(page)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if cacheTtl <= 0 {
+		return
+	}
+	if expire, found := db.CacheExpire(cacheKey); found && !expire.IsZero() {
+		w.Header().Set("Last-Modified", expire.Add(-cacheTtl).UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,52 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+func TestTemplatesGet(t *testing.T) {
+	path := t.TempDir()
+	file := filepath.Join(path, TemplatesDir, "index.twig")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(source string) {
+		if err := os.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := &templates{path: path, dir: TemplatesDir, templateSets: make(map[string]*pongo2.TemplateSet),
+		loaders: make(map[string]*dependencyLoader)}
+	ts.reset()
+	render := func() string {
+		template, err := ts.get("index", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := template.Execute(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	write("first")
+	if got := render(); got != "first" {
+		t.Fatalf("render = %q", got)
+	}
+	// parsed template is cached until the watcher resets templates
+	write("second")
+	if got := render(); got != "first" {
+		t.Fatalf("render = %q before reset", got)
+	}
+	ts.reset()
+	if got := render(); got != "second" {
+		t.Fatalf("render = %q after reset", got)
+	}
+	if _, err := ts.get("missing", "", ""); err != ErrTemplateNotFound {
+		t.Fatalf("missing template error = %v", err)
+	}
+}
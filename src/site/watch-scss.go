@@ -3,6 +3,7 @@ package site
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
@@ -18,15 +19,27 @@ func WatchScss(path string, configPath string) {
 	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
 		rebuildTimeout = time.Millisecond * 100
 	}
+	done := watchDone(filepath.Dir(configPath))
 	go func() {
 		mu := sync.Mutex{}
 		lastChange := time.Now()
 		for {
+			select {
+			case <-done:
+				// site is removed
+				return
+			default:
+			}
 			// Check if path still exists before watching
 			if _, err := os.Stat(path); os.IsNotExist(err) {
 				log.Printf("scss directory %s no longer exists, stopping watch", path)
 				break
 			}
+			// site is removed
+			if _, err := os.Stat(configPath); os.IsNotExist(err) {
+				log.Printf("config %s no longer exists, stopping scss watch", configPath)
+				break
+			}
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
@@ -44,12 +57,20 @@ func WatchScss(path string, configPath string) {
 				}
 				defer notify.Stop(c)
 				// waiting the signal after changing scss files
-				ei := <-c
+				var ei notify.EventInfo
+				select {
+				case ei = <-c:
+				case <-done:
+					return
+				}
 				// Check if directory was deleted
 				if _, err := os.Stat(path); os.IsNotExist(err) {
 					log.Printf("scss directory %s was deleted, stopping watch", path)
 					return
 				}
+				if _, err := os.Stat(configPath); os.IsNotExist(err) {
+					return
+				}
 				mu.Lock()
 				lastChange = time.Now()
 				mu.Unlock()
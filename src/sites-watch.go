@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// watchSitesPath calls sync when directories are added to or removed from sites_path. Sites copied without
+// config.toml first are registered by the periodic check when config.toml appears.
+func watchSitesPath(sitesPath string, sync func()) {
+	changed := make(chan struct{}, 1)
+	go func() {
+		for {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Println("error in sites path watching routine", r)
+						time.Sleep(time.Second * 30)
+					}
+				}()
+				if _, err := os.Stat(sitesPath); err != nil {
+					time.Sleep(time.Second * 30)
+					return
+				}
+				c := make(chan notify.EventInfo, 10)
+				if err := notify.Watch(sitesPath, c, notify.Create, notify.Remove, notify.Rename); err != nil {
+					log.Panicln(sitesPath, err)
+				}
+				defer notify.Stop(c)
+				for range c {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			}()
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Second * 30)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-changed:
+				// waiting until the site directory is copied
+				time.Sleep(time.Second * 2)
+			case <-ticker.C:
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Println("error in sites sync", r)
+					}
+				}()
+				sync()
+			}()
+		}
+	}()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+
+	"sersh.com/totaltube/frontend/api"
+	"sersh.com/totaltube/frontend/db"
+	"sersh.com/totaltube/frontend/handlers"
+	"sersh.com/totaltube/frontend/helpers"
+	"sersh.com/totaltube/frontend/internal"
+	"sersh.com/totaltube/frontend/site"
+	"sersh.com/totaltube/frontend/types"
+)
+
+type TestTemplatesCmd struct {
+	Site   string `arg:"" optional:"" help:"hostname of the site to test, all sites with tests directory by default"`
+	Update bool   `name:"update" help:"write rendered html to golden files instead of comparing"`
+}
+
+// templateFixture is a test case from sites/<host>/tests/<name>.yaml (or .yml, .json).
+// Rendered html is compared with sites/<host>/tests/<name>.html.
+type templateFixture struct {
+	Url      string                 `yaml:"url" json:"url"`           // page rendered through site routes
+	Template string                 `yaml:"template" json:"template"` // or template rendered directly with the context
+	Lang     string                 `yaml:"lang" json:"lang"`
+	Context  map[string]interface{} `yaml:"context" json:"context"`
+	Api      map[string]interface{} `yaml:"api" json:"api"` // canned minion responses by endpoint, optionally with query
+}
+
+var apiPathVersionRegex = regexp.MustCompile(`^/?v\d+/`)
+
+// fakeMinion serves canned api responses of the current fixture.
+type fakeMinion struct {
+	sync.Mutex
+	responses map[string]json.RawMessage
+	missing   map[string]bool
+}
+
+func apiResponseKey(uri, query string) string {
+	uri = strings.Trim(uri, "/")
+	if values, err := url.ParseQuery(query); err == nil && len(values) > 0 {
+		return uri + "?" + values.Encode()
+	}
+	return uri
+}
+
+func (m *fakeMinion) set(responses ...map[string]interface{}) error {
+	m.Lock()
+	defer m.Unlock()
+	m.responses = map[string]json.RawMessage{
+		"options":        json.RawMessage(`{}`),
+		"languages":      json.RawMessage(`[{"id":"en","name":"English","locale":"en_US","native":"English","direction":"ltr","country":"us"}]`),
+		"country-groups": json.RawMessage(`[{"id":1,"name":"_all","countries":[]}]`),
+	}
+	m.missing = map[string]bool{}
+	for _, r := range responses {
+		for k, v := range r {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			uri, query, _ := strings.Cut(k, "?")
+			m.responses[apiResponseKey(uri, query)] = data
+		}
+	}
+	return nil
+}
+
+func (m *fakeMinion) response(method, uri, query string) (success bool, value json.RawMessage) {
+	m.Lock()
+	defer m.Unlock()
+	if method != http.MethodGet && method != "" {
+		return true, json.RawMessage(`null`)
+	}
+	key := apiResponseKey(uri, query)
+	if v, ok := m.responses[key]; ok {
+		return true, v
+	}
+	if v, ok := m.responses[strings.Trim(uri, "/")]; ok {
+		return true, v
+	}
+	m.missing[key] = true
+	return false, json.RawMessage(`"not found"`)
+}
+
+func (m *fakeMinion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := apiPathVersionRegex.ReplaceAllString(r.URL.Path, "")
+	w.Header().Set("Content-Type", "application/json")
+	type response struct {
+		Success bool            `json:"success"`
+		Value   json.RawMessage `json:"value"`
+	}
+	if uri == "batch" {
+		var batch struct {
+			Requests []struct {
+				Method string `json:"method"`
+				Uri    string `json:"uri"`
+				Query  string `json:"query"`
+			} `json:"requests"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		responses := make([]response, 0, len(batch.Requests))
+		for _, req := range batch.Requests {
+			success, value := m.response(req.Method, req.Uri, req.Query)
+			responses = append(responses, response{Success: success, Value: value})
+		}
+		value, _ := json.Marshal(responses)
+		_ = json.NewEncoder(w).Encode(response{Success: true, Value: value})
+		return
+	}
+	success, value := m.response(r.Method, uri, r.URL.RawQuery)
+	_ = json.NewEncoder(w).Encode(response{Success: success, Value: value})
+}
+
+func (m *fakeMinion) missingResponses() (keys []string) {
+	m.Lock()
+	defer m.Unlock()
+	for k := range m.missing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func readFixture(file string, fixture interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(file) == ".json" {
+		return json.Unmarshal(data, fixture)
+	}
+	return yaml.Unmarshal(data, fixture)
+}
+
+func fixtureFiles(testsPath string) (files []string) {
+	for _, ext := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, _ := filepath.Glob(filepath.Join(testsPath, ext))
+		for _, m := range matches {
+			if !strings.HasPrefix(filepath.Base(m), "_") {
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return
+}
+
+// renderFixture renders the fixture page or template without cache.
+func renderFixture(handler http.Handler, sitePath string, config *types.Config, fixture *templateFixture) ([]byte, error) {
+	host := filepath.Base(sitePath)
+	lang := helpers.FirstNotEmpty(fixture.Lang, config.General.DefaultLanguage)
+	if fixture.Url != "" {
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+fixture.Url, nil)
+		req.RemoteAddr = "127.0.0.1:1"
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec.Body.Bytes(), fmt.Errorf("%s returned status %d", fixture.Url, rec.Code)
+		}
+		return rec.Body.Bytes(), nil
+	}
+	if fixture.Template == "" {
+		return nil, fmt.Errorf("url or template must be set")
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, chi.NewRouteContext())
+	ctx = context.WithValue(ctx, types.ContextKeyConfig, config)
+	ctx = context.WithValue(ctx, types.ContextKeyPath, sitePath)
+	ctx = context.WithValue(ctx, types.ContextKeyHostName, host)
+	ctx = context.WithValue(ctx, types.ContextKeyLang, lang)
+	ctx = context.WithValue(ctx, types.ContextKeyIp, "127.0.0.1")
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+	customContext := handlers.TemplateContext(rec, req, fixture.Template)
+	customContext.Update(fixture.Context)
+	return site.ParseTemplate(fixture.Template, sitePath, config, customContext, true, "", 0,
+		func() (pongo2.Context, error) {
+			return pongo2.Context{}, nil
+		}, rec, req)
+}
+
+// firstDifference returns the first differing line of rendered and golden html.
+func firstDifference(rendered, golden []byte) string {
+	renderedLines := strings.Split(string(rendered), "\n")
+	goldenLines := strings.Split(string(golden), "\n")
+	for i := 0; i < len(renderedLines) || i < len(goldenLines); i++ {
+		var r, g string
+		if i < len(renderedLines) {
+			r = renderedLines[i]
+		}
+		if i < len(goldenLines) {
+			g = goldenLines[i]
+		}
+		if r != g {
+			return fmt.Sprintf("line %d:\n      golden:   %s\n      rendered: %s", i+1, g, r)
+		}
+	}
+	return ""
+}
+
+// TestTemplates renders fixtures from tests directory of the sites with canned api responses and compares
+// them to golden html files. Exits with non-zero code if any of them differs.
+func TestTemplates() {
+	if failed := runTemplateTests(CLI.TestTemplates); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runTemplateTests(opts TestTemplatesCmd) (failed int) {
+	var sitePaths []string
+	if opts.Site != "" {
+		sitePaths = []string{filepath.Join(internal.Config.Frontend.SitesPath, internal.NormalizeHost(opts.Site))}
+	} else {
+		matches, _ := filepath.Glob(filepath.Join(internal.Config.Frontend.SitesPath, "*", "tests"))
+		for _, m := range matches {
+			sitePaths = append(sitePaths, filepath.Dir(m))
+		}
+	}
+	minion := new(fakeMinion)
+	if err := minion.set(); err != nil {
+		log.Fatalln(err)
+	}
+	server := httptest.NewServer(minion)
+	defer server.Close()
+	internal.Config.General.ApiUrl = server.URL + "/"
+	internal.Config.General.Development = false
+	internal.Config.Offline.Enable = false
+	tmp, err := os.MkdirTemp("", "totaltube-test-templates-")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer os.RemoveAll(tmp)
+	internal.Config.Database.Path = tmp
+	internal.Config.Database.RestoreFromBackup = false
+	internal.Config.Database.BackupPath = ""
+	db.InitDB()
+	defer db.BeforeClose()
+	internal.Config.Options, _ = api.Options(nil)
+	initLanguages()
+	site.InitPongo2()
+	helpers.InitMinifier()
+	initCountryGroups()
+	handler := InitRouter()
+	passed := 0
+	for _, sitePath := range sitePaths {
+		testsPath := filepath.Join(sitePath, "tests")
+		config := internal.GetConfig(filepath.Join(sitePath, "config.toml"), api.UpdateConfigRetry)
+		config.General.ApiUrl = ""
+		var shared map[string]interface{}
+		for _, name := range []string{"_api.yaml", "_api.yml", "_api.json"} {
+			if _, err := os.Stat(filepath.Join(testsPath, name)); err == nil {
+				if err = readFixture(filepath.Join(testsPath, name), &shared); err != nil {
+					log.Fatalln(name+":", err)
+				}
+			}
+		}
+		for _, file := range fixtureFiles(testsPath) {
+			name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			testName := filepath.Base(sitePath) + "/" + name
+			fail := func(message string) {
+				failed++
+				fmt.Println("FAIL", testName+":", message)
+			}
+			var fixture templateFixture
+			if err := readFixture(file, &fixture); err != nil {
+				fail(err.Error())
+				continue
+			}
+			if err := minion.set(shared, fixture.Api); err != nil {
+				fail(err.Error())
+				continue
+			}
+			// responses of the previous fixture must not be taken from cache
+			_ = db.ClearCacheByPrefix("")
+			rendered, err := renderFixture(handler, sitePath, config, &fixture)
+			if missing := minion.missingResponses(); len(missing) > 0 {
+				fmt.Println("     ", testName+": no canned api response for", strings.Join(missing, ", "))
+			}
+			if err != nil {
+				fail(err.Error())
+				continue
+			}
+			goldenFile := filepath.Join(testsPath, name+".html")
+			if opts.Update {
+				if err = os.WriteFile(goldenFile, rendered, 0644); err != nil {
+					fail(err.Error())
+					continue
+				}
+				passed++
+				fmt.Println("UPDATED", testName)
+				continue
+			}
+			golden, err := os.ReadFile(goldenFile)
+			if err != nil {
+				fail("no golden file " + filepath.Base(goldenFile) + ", run with --update to create it")
+				continue
+			}
+			if !bytes.Equal(rendered, golden) {
+				fail("rendered html differs from golden file, " + firstDifference(rendered, golden))
+				continue
+			}
+			passed++
+			fmt.Println("ok  ", testName)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	return
+}
@@ -10,4 +10,7 @@ const (
 	ContextKeyHostName           ContextKey = "hostName"
 	ContextKeyCustomTemplateName ContextKey = "custom_template_name"
 	ContextKeyIp                 ContextKey = "ip"
+	ContextKeyPreview            ContextKey = "preview"
+	ContextKeyRouteOptions       ContextKey = "route_options"
+	ContextKeySubdomain          ContextKey = "subdomain"
 )
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"sync"
+	"time"
+)
+
+// DebugCall is an api, fetch or extension call made while serving the request.
+type DebugCall struct {
+	Kind     string
+	Name     string
+	Duration time.Duration
+	Error    string
+}
+
+// DebugError is a template or extension error shown on the error page in development mode.
+type DebugError struct {
+	Kind        string // template or extension
+	File        string
+	Line        int
+	Column      int
+	Sender      string
+	Message     string
+	ContextKeys []string
+}
+
+// DebugTrace collects information about the request for the debug toolbar shown in development mode.
+// All methods can be called on nil trace.
+type DebugTrace struct {
+	sync.Mutex
+	Template     string
+	CacheKey     string
+	CacheStatus  string // hit, miss, stale or nocache
+	CacheTtl     time.Duration
+	RenderTime   time.Duration
+	DynamicTime  time.Duration
+	Language     string
+	CountryGroup string
+	Calls        []DebugCall
+	Errors       []DebugError
+}
+
+func (t *DebugTrace) AddCall(kind, name string, started time.Time, err error) {
+	if t == nil {
+		return
+	}
+	call := DebugCall{Kind: kind, Name: name, Duration: time.Since(started)}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	t.Lock()
+	t.Calls = append(t.Calls, call)
+	t.Unlock()
+}
+
+func (t *DebugTrace) SetCache(key, status string, ttl time.Duration) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	t.CacheKey, t.CacheStatus, t.CacheTtl = key, status, ttl
+	t.Unlock()
+}
+
+func (t *DebugTrace) SetPage(template, language, countryGroup string) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	t.Template, t.Language, t.CountryGroup = template, language, countryGroup
+	t.Unlock()
+}
+
+func (t *DebugTrace) AddRenderTime(render, dynamic time.Duration) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	t.RenderTime += render
+	t.DynamicTime += dynamic
+	t.Unlock()
+}
+
+func (t *DebugTrace) AddError(e DebugError) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	t.Errors = append(t.Errors, e)
+	t.Unlock()
+}
+
+// FirstError returns the first error happened while serving the request.
+func (t *DebugTrace) FirstError() (e DebugError, ok bool) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	if len(t.Errors) == 0 {
+		return
+	}
+	return t.Errors[0], true
+}
+
+// Trace returns debug trace of the request or nil if the config is not bound to the request.
+func (c *Config) Trace() *DebugTrace {
+	if c == nil {
+		return nil
+	}
+	return c.DebugTrace
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// Active returns true if the scheduled theme is active at the time. Zero Start or End means open interval.
+func (s *ConfigSchedule) Active(now time.Time) bool {
+	return (s.Start.IsZero() || !now.Before(s.Start)) && (s.End.IsZero() || now.Before(s.End))
+}
+
+// ActiveSchedule returns the first theme of [[schedule]] active at the time or nil.
+func (c *Config) ActiveSchedule(now time.Time) *ConfigSchedule {
+	for i := range c.Schedule {
+		if c.Schedule[i].Active(now) {
+			return &c.Schedule[i]
+		}
+	}
+	return nil
+}
+
+// ActiveTemplates returns templates overlay directory of the active theme, empty if there is none.
+func (c *Config) ActiveTemplates(now time.Time) string {
+	if s := c.ActiveSchedule(now); s != nil {
+		return s.Templates
+	}
+	return ""
+}
+
+// NextScheduleChange returns the nearest start or end of the themes after the time, zero time if there is none.
+func (c *Config) NextScheduleChange(now time.Time) (next time.Time) {
+	for _, s := range c.Schedule {
+		for _, t := range []time.Time{s.Start, s.End} {
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	return
+}
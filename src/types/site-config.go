@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type (
 	ConfigTranslations struct {
 		Translations map[string]map[string]string `toml:"translations"`
@@ -11,12 +13,19 @@ type (
 		Params          ConfigParams
 		Related         ConfigRelated
 		CacheTimeouts   CacheTimeouts                `toml:"cache_timeouts"`
+		CacheHeaders    map[string]CacheHeadersRule  `toml:"cache_headers"`
+		RouteOptions    map[string]RouteOptions      `toml:"route_options" json:"-"`
+		Maintenance     ConfigMaintenance            `toml:"maintenance" json:"-"`
+		Schedule        []ConfigSchedule             `toml:"schedule" json:"-"`
 		LanguageDomains map[string]string            `toml:"language_domains"`
+		Aliases         []string                     `toml:"aliases" json:"-"`        // additional hosts: exact, *.example.com or ~regex
+		CanonicalHost   string                       `toml:"canonical_host" json:"-"` // apex or www, redirects the other one
 		Translations    map[string]map[string]string `toml:"translations"`
 		Javascript      ConfigJs                     `json:"-"`
 		Scss            ConfigScss                   `json:"-"`
 		Custom          map[string]string            `json:"-"`
 		Hostname        string                       `json:"-"`
+		DebugTrace      *DebugTrace                  `toml:"-" json:"-"` // set on request copy of config in development mode
 	}
 	ConfigRelated struct {
 		TitleTranslated              *bool    `toml:"title_translated"`
@@ -111,6 +120,7 @@ type (
 		Rotation               string `toml:"rotation"`
 		RotationTrade          string `toml:"rotation_trade"`
 		Skim                   string `toml:"skim"`
+		Preview                string `toml:"preview" json:"-"`
 	}
 	ConfigJs struct {
 		Entries     []string `toml:"entries"`
@@ -150,6 +160,7 @@ type (
 		Debug                              bool     `toml:"debug"`
 		ApiUrl                             string   `toml:"api_url"`
 		ApiSecret                          string   `toml:"api_secret"`
+		ApiVersion                         string   `toml:"api_version"`
 		ToplistDataUrl                     string   `toml:"toplist_data_url"`                 // url to json file with toplist data for trade scripts
 		IncludeToplistLanguageLinks        bool     `toml:"include_toplist_language_links"`   // if true, language links will be included in toplist data
 		RouteForToplistLanguageLinks       string   `toml:"route_for_toplist_language_links"` // route to use for toplist language links
@@ -158,6 +169,43 @@ type (
 		RandomizeRatio                     float64  `toml:"randomize_ratio"`
 		CacheKeyQueryParams                []string `toml:"cache_key_query_params"` // query params to mention in cache key
 		CanonicalNoPagination              *bool    `toml:"canonical_no_pagination"`
+		DeviceTemplates                    bool     `toml:"device_templates"` // mobile/tablet/desktop/bot template variants and cache keys
+	}
+	// ConfigSchedule is a theme active from Start till End: templates and static files overlays and css/js entries.
+	ConfigSchedule struct {
+		Name        string    `toml:"name"`
+		Start       time.Time `toml:"start"`
+		End         time.Time `toml:"end"`
+		Templates   string    `toml:"templates"` // directory with templates overriding templates directory, like templates-xmas
+		Static      string    `toml:"static"`    // directory with static files served before public directory
+		ScssEntries []string  `toml:"scss_entries"`
+		JsEntries   []string  `toml:"js_entries"`
+	}
+	// RouteOptions are options of the built-in or custom route by its name in [routes] section.
+	RouteOptions struct {
+		BadBot       *bool     `toml:"bad_bot"`       // check for bad bots, on by default
+		Timeout      Duration  `toml:"timeout"`       // request timeout
+		RateLimit    int       `toml:"rate_limit"`    // max requests per minute from one ip
+		CacheTimeout *Duration `toml:"cache_timeout"` // rendered page cache timeout instead of the default one, 0 disables the cache
+		CacheHeaders string    `toml:"cache_headers"` // name of [cache_headers] rule instead of the route name
+	}
+	// ConfigMaintenance closes the site, or makes it read-only, for everyone except allowed ips and cookie.
+	ConfigMaintenance struct {
+		Enabled     bool     `toml:"enabled" json:"enabled"`
+		ReadOnly    bool     `toml:"read_only" json:"read_only"`       // only GET and HEAD requests are served, rating is not counted
+		RetryAfter  Duration `toml:"retry_after" json:"retry_after"`   // Retry-After header, 1 hour by default
+		AllowIps    []string `toml:"allow_ips" json:"allow_ips"`       // ips and networks like 10.0.0.0/8 the site is served to as usual
+		AllowCookie string   `toml:"allow_cookie" json:"allow_cookie"` // name=value of the cookie the site is served with as usual
+		AllowPaths  []string `toml:"allow_paths" json:"allow_paths"`   // path prefixes served as usual, like static files of 503 page
+	}
+	// CacheHeadersRule is the http caching policy of a route for browsers and CDN.
+	CacheHeadersRule struct {
+		MaxAge               *Duration `toml:"max_age"`
+		SMaxAge              *Duration `toml:"s_maxage"`
+		StaleWhileRevalidate *Duration `toml:"stale_while_revalidate"`
+		StaleIfError         *Duration `toml:"stale_if_error"`
+		Private              bool      `toml:"private"`
+		Vary                 []string  `toml:"vary"`
 	}
 	CacheTimeouts struct {
 		ContentItem             *Duration `toml:"content_item"`
@@ -270,6 +318,7 @@ func NewConfig() *Config {
 			Rotation:               "rot",
 			RotationTrade:          "tr",
 			Skim:                   "s",
+			Preview:                "preview",
 		},
 		LanguageDomains: make(map[string]string),
 		Translations:    make(map[string]map[string]string),